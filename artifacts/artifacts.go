@@ -0,0 +1,259 @@
+// Package artifacts captures the state of the page when a step fails: a screenshot, the HTML, the url,
+// the console log and optionally a Playwright trace, listed in a manifest
+package artifacts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/log"
+)
+
+// ManifestFile lists the captured failures, at the root of the artifacts directory
+const ManifestFile = "manifest.json"
+
+const (
+	// MaxFailures captured in a run, steps failing in a loop with `on-error: ignore` would fill the disk otherwise
+	MaxFailures = 20
+	// maxConsole messages are kept, the oldest ones are dropped
+	maxConsole = 1000
+	// captureTimeout bounds the screenshot of a page that may be stuck
+	captureTimeout = 10_000
+)
+
+// Manifest describes the failures of a run
+type Manifest struct {
+	Failures []Failure `json:"failures"`
+}
+
+// Failure is a failed step and the files captured for it, file names are relative to the artifacts directory
+type Failure struct {
+	// Path locates the step, e.g. `loop: {{ .items }} > #2 > click: #buy`
+	Path  string    `json:"path"`
+	Type  string    `json:"type"`
+	Error string    `json:"error"`
+	URL   string    `json:"url,omitempty"`
+	Time  time.Time `json:"time"`
+	Files Files     `json:"files"`
+}
+
+// Files are missing when they could not be captured, e.g. the screenshot of a closed page
+type Files struct {
+	Screenshot string `json:"screenshot,omitempty"`
+	HTML       string `json:"html,omitempty"`
+	Console    string `json:"console,omitempty"`
+	Trace      string `json:"trace,omitempty"`
+}
+
+// Recorder captures the failures of a single run into its directory.
+// A nil Recorder captures nothing
+type Recorder struct {
+	dir   string
+	trace bool
+
+	lock     sync.Mutex
+	context  playwright.BrowserContext
+	tracing  bool
+	captured []error
+	manifest Manifest
+
+	// console has its own lock, console events must not wait for a capture in progress
+	consoleLock sync.Mutex
+	console     []string
+}
+
+// New returns a recorder writing into dir, trace also saves a Playwright trace of the run up to each failure
+func New(dir string, trace bool) *Recorder {
+	return &Recorder{dir: dir, trace: trace}
+}
+
+// Dir of the artifacts
+func (r *Recorder) Dir() string {
+	if r == nil {
+		return ""
+	}
+	return r.dir
+}
+
+// Attach starts recording the console messages of the browser context and its Playwright trace, when enabled
+func (r *Recorder) Attach(ctx playwright.BrowserContext) error {
+	if r == nil {
+		return nil
+	}
+	r.context = ctx
+	ctx.OnConsole(r.recordConsole)
+	if !r.trace {
+		return nil
+	}
+	err := ctx.Tracing().Start(playwright.TracingStartOptions{
+		Screenshots: playwright.Bool(true),
+		Snapshots:   playwright.Bool(true),
+		Sources:     playwright.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start the trace of failure artifacts: %w", err)
+	}
+	r.tracing = true
+	return nil
+}
+
+// Close stops the Playwright trace, it must be called before the browser context is closed
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.tracing {
+		r.tracing = false
+		if err := r.context.Tracing().Stop(); err != nil {
+			slog.Warn("failed to stop the trace of failure artifacts", log.ErrVal(err))
+		}
+	}
+	if len(r.manifest.Failures) > 0 {
+		slog.Warn("failure artifacts saved", slog.String("manifest", filepath.Join(r.dir, ManifestFile)), slog.Int("failures", len(r.manifest.Failures)))
+	}
+}
+
+func (r *Recorder) recordConsole(msg playwright.ConsoleMessage) {
+	line := fmt.Sprintf("%s [%s] %s", time.Now().Format(time.RFC3339Nano), msg.Type(), msg.Text())
+	r.consoleLock.Lock()
+	defer r.consoleLock.Unlock()
+	if len(r.console) >= maxConsole {
+		r.console = r.console[1:]
+	}
+	r.console = append(r.console, line)
+}
+
+// Capture saves the state of the page for the failure of the step at path. An error is captured once,
+// by the innermost step it failed, blocks returning it again are skipped
+func (r *Recorder) Capture(page playwright.Page, path, stepType string, err error) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, captured := range r.captured {
+		if errors.Is(err, captured) {
+			return
+		}
+	}
+	r.captured = append(r.captured, err)
+	if len(r.manifest.Failures) >= MaxFailures {
+		slog.Warn("too many failures, artifacts are not captured", slog.String("step", path), slog.Int("max", MaxFailures))
+		return
+	}
+
+	name := fmt.Sprintf("failure-%d", len(r.manifest.Failures)+1)
+	failure := Failure{
+		Path:  path,
+		Type:  stepType,
+		Error: err.Error(),
+		Time:  time.Now(),
+	}
+	if err := os.MkdirAll(filepath.Join(r.dir, name), 0o755); err != nil {
+		slog.Error("failed to create artifacts directory", slog.String("dir", r.dir), log.ErrVal(err))
+		return
+	}
+	failure.URL = page.URL()
+	failure.Files.Screenshot = r.save(name, "screenshot.png", func(file string) error {
+		_, err := page.Screenshot(playwright.PageScreenshotOptions{
+			Path:     playwright.String(file),
+			FullPage: playwright.Bool(true),
+			Timeout:  playwright.Float(captureTimeout),
+		})
+		return err
+	})
+	failure.Files.HTML = r.save(name, "page.html", func(file string) error {
+		html, err := page.Content()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(file, []byte(html), 0o644)
+	})
+	failure.Files.Console = r.save(name, "console.log", func(file string) error {
+		return os.WriteFile(file, []byte(r.consoleLog()), 0o644)
+	})
+	if r.tracing {
+		failure.Files.Trace = r.save(name, "trace.zip", r.saveTrace)
+	}
+
+	r.manifest.Failures = append(r.manifest.Failures, failure)
+	if err := r.writeManifest(); err != nil {
+		slog.Error("failed to write artifacts manifest", slog.String("dir", r.dir), log.ErrVal(err))
+	}
+}
+
+func (r *Recorder) consoleLog() string {
+	r.consoleLock.Lock()
+	defer r.consoleLock.Unlock()
+	return strings.Join(r.console, "\n")
+}
+
+// save writes one artifact of the failure, it returns its name relative to the artifacts directory
+// or an empty string when it could not be captured
+func (r *Recorder) save(failure, name string, write func(file string) error) string {
+	rel := filepath.Join(failure, name)
+	if err := write(filepath.Join(r.dir, rel)); err != nil {
+		slog.Warn("failed to capture artifact", slog.String("artifact", rel), log.ErrVal(err))
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// saveTrace exports the trace recorded so far and starts a new chunk for the next failure
+func (r *Recorder) saveTrace(file string) error {
+	tracing := r.context.Tracing()
+	if err := tracing.StopChunk(file); err != nil {
+		return err
+	}
+	return tracing.StartChunk()
+}
+
+func (r *Recorder) writeManifest() error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// Step paths are separated by `>`
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r.manifest); err != nil {
+		return err
+	}
+	tmp := filepath.Join(r.dir, ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(r.dir, ManifestFile))
+}
+
+// ReadManifest loads the manifest of an artifacts directory, it fails with fs.ErrNotExist when nothing was captured
+func ReadManifest(dir string) (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to decode artifacts manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// File resolves the name of an artifact in dir, names escaping the directory are rejected
+func File(dir, name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if rel == "." || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid artifact name %q", name)
+	}
+	return filepath.Join(dir, rel), nil
+}
@@ -19,9 +19,11 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/fmotalleb/go-tools/git"
 	"github.com/spf13/cobra"
@@ -30,14 +32,26 @@ import (
 	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/engine"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/monitor"
+	"github.com/fmotalleb/scrapper-go/notify"
+	"github.com/fmotalleb/scrapper-go/telemetry"
+	"github.com/fmotalleb/scrapper-go/trace"
 	"github.com/fmotalleb/scrapper-go/utils"
 )
 
+const telemetryFlushTimeout = 5 * time.Second
+
 var (
-	cfgFile  string
-	cfg      config.ExecutionConfig
-	format   string
-	logLevel string
+	cfgFile      string
+	cfg          config.ExecutionConfig
+	format       string
+	logLevel     string
+	monitorState string
+	diffOnly     bool
+	traceFormat  string
+	otelConfig   telemetry.Config
+
+	shutdownTelemetry = func() {}
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -51,14 +65,40 @@ var rootCmd = &cobra.Command{
 			panic(err)
 		}
 		slog.Debug("level Set To", slog.String("level", logLevel))
+		setupTelemetry()
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		shutdownTelemetry()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		result, err := engine.ExecuteConfig(context.Background(), cfg)
+		if traceFormat != "" && traceFormat != "text" && traceFormat != "json" {
+			slog.Error("unknown trace format, expected text or json", slog.String("format", traceFormat))
+			return
+		}
+		ctx := context.Background()
+		var opts []engine.Option
+		var tr *trace.Node
+		if traceFormat != "" {
+			tr = trace.New()
+			opts = append(opts, engine.WithTrace(tr))
+		}
+		result, err := engine.ExecuteConfig(ctx, cfg, opts...)
+		if tr != nil {
+			printTrace(tr)
+		}
+		output := result
+		var diff *monitor.Diff
 		if err != nil {
 			slog.Error("failed to execute command", log.ErrVal(err))
+		} else if output, diff, err = applyMonitor(result); err != nil {
+			slog.Error("failed to compare with the previous run", log.ErrVal(err))
+			_ = notify.Dispatch(ctx, cfg.Pipeline.Notify, notify.NewEvent(result, err, nil))
+			return
 		}
+		// Failing hooks are logged by notify and do not change the output
+		_ = notify.Dispatch(ctx, cfg.Pipeline.Notify, notify.NewEvent(result, err, diff))
 
-		formatted, err := utils.Output(format).Format(result)
+		formatted, err := utils.Output(format).Format(output)
 		if err != nil {
 			slog.Error("failed to format", log.ErrVal(err))
 			return
@@ -83,8 +123,31 @@ func init() {
 
 	rootCmd.Flags().StringVarP(&cfgFile, "config", "c", "", "config file (default is $HOME/.scrapper-go.yaml)")
 	rootCmd.Flags().StringVar(&format, "format", "json", "output format (json,yaml) defaults to json")
+	rootCmd.Flags().StringVar(&monitorState, "monitor", "", "monitor mode: compare the output with the previous run saved in this state file")
+	rootCmd.Flags().BoolVar(&diffOnly, "diff-only", false, "in monitor mode, print only the diff against the previous run")
+	rootCmd.Flags().StringVar(&traceFormat, "trace", "", "print the trace of the executed steps to stderr (text,json), --trace alone prints text")
+	rootCmd.Flags().Lookup("trace").NoOptDefVal = "text"
 
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "WARN", "Log Level (DEBUG INFO WARN ERROR) set to DEBUG for verbose logging")
+	rootCmd.PersistentFlags().StringVar(&otelConfig.Exporter, "otel-exporter", "", "export OpenTelemetry spans of runs and steps (otlp,stdout,file), disabled by default")
+	rootCmd.PersistentFlags().StringVar(&otelConfig.Endpoint, "otel-endpoint", "", "url of the OTLP HTTP collector (e.g. http://localhost:4318), defaults to OTEL_EXPORTER_OTLP_* variables")
+	rootCmd.PersistentFlags().StringVar(&otelConfig.File, "otel-file", "", "file receiving the spans of the file exporter")
+}
+
+// setupTelemetry installs the span exporter selected by the --otel-* flags
+func setupTelemetry() {
+	shutdown, err := telemetry.Setup(context.Background(), otelConfig)
+	if err != nil {
+		slog.Error("failed to setup opentelemetry", log.ErrVal(err))
+		os.Exit(1)
+	}
+	shutdownTelemetry = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetryFlushTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			slog.Error("failed to flush opentelemetry spans", log.ErrVal(err))
+		}
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -115,3 +178,38 @@ func initConfig() {
 	}
 	slog.Debug("loaded config file", slog.Any("config", cfg))
 }
+
+// applyMonitor compares the result with the previous run when monitor mode is enabled by flags or config,
+// diff is nil when monitor mode is disabled
+func applyMonitor(result map[string]any) (map[string]any, *monitor.Diff, error) {
+	mon := cfg.Pipeline.Monitor
+	if monitorState != "" {
+		mon.State = monitorState
+	}
+	mon.DiffOnly = mon.DiffOnly || diffOnly
+	if !mon.Enabled() {
+		return result, nil, nil
+	}
+	out, diff, err := monitor.Apply(mon, result)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("compared with the previous run", slog.Bool("changed", diff.HasChanges()), slog.Bool("initial", diff.Initial))
+	return out, &diff, nil
+}
+
+// printTrace writes the trace to stderr, keeping stdout for the output
+func printTrace(tr *trace.Node) {
+	out := tr.Summary()
+	if traceFormat == "json" {
+		data, err := json.MarshalIndent(tr, "", "  ")
+		if err != nil {
+			slog.Error("failed to encode trace", log.ErrVal(err))
+			return
+		}
+		out = string(data) + "\n"
+	}
+	if _, err := fmt.Fprint(os.Stderr, out); err != nil {
+		slog.Error("failed to print trace", log.ErrVal(err))
+	}
+}
@@ -4,16 +4,31 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 
+	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/pool"
 	"github.com/fmotalleb/scrapper-go/server"
 )
 
 type serveArgs struct {
-	address string
-	port    uint32
+	address       string
+	port          uint32
+	poolSize      int
+	poolMaxUses   int
+	jobsDir       string
+	jobsRetention time.Duration
+	schedulesDir  string
+	keysFile      string
+	policyFile    string
+	limitsDefault map[string]string
+	limitsCap     map[string]string
+	artifactsDir  string
+	allowWebhooks bool
 }
 
 var serverArg serveArgs
@@ -23,8 +38,36 @@ var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Serve service as an api endpoint",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := server.StartServer(fmt.Sprintf("%s:%d", serverArg.address, serverArg.port)); err != nil {
+		limitsDefault, err := parseLimits(serverArg.limitsDefault)
+		if err != nil {
+			slog.Error("invalid --limits-default", log.ErrVal(err))
+			os.Exit(1)
+		}
+		limitsCap, err := parseLimits(serverArg.limitsCap)
+		if err != nil {
+			slog.Error("invalid --limits-cap", log.ErrVal(err))
+			os.Exit(1)
+		}
+		cfg := server.Config{
+			Address:     fmt.Sprintf("%s:%d", serverArg.address, serverArg.port),
+			PoolEnabled: serverArg.poolSize >= 0,
+			Pool: pool.Config{
+				MaxConcurrent: serverArg.poolSize,
+				MaxUses:       serverArg.poolMaxUses,
+			},
+			JobsDir:       serverArg.jobsDir,
+			JobsRetention: serverArg.jobsRetention,
+			SchedulesDir:  serverArg.schedulesDir,
+			KeysFile:      serverArg.keysFile,
+			PolicyFile:    serverArg.policyFile,
+			LimitsDefault: limitsDefault,
+			LimitsCap:     limitsCap,
+			ArtifactsDir:  serverArg.artifactsDir,
+			AllowWebhooks: serverArg.allowWebhooks,
+		}
+		if err := server.StartServer(cfg); err != nil {
 			slog.Error("error starting server", log.ErrVal(err))
+			shutdownTelemetry()
 			os.Exit(1)
 		}
 	},
@@ -33,6 +76,31 @@ var serveCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(serveCmd)
 
-	serveCmd.Flags().StringVarP(&serverArg.address, "address", "a", "127.0.0.1", "change this value if you want to expose server (since this app does not support authentication keep it behind a reverse proxy)")
-	serveCmd.Flags().Uint32VarP(&serverArg.port, "port", "p", 8080, "port on which the service will be exposed (since this app does not support authentication keep it behind a reverse proxy)")
+	serveCmd.Flags().StringVarP(&serverArg.address, "address", "a", "127.0.0.1", "change this value if you want to expose server (without --auth-keys keep it behind a reverse proxy)")
+	serveCmd.Flags().Uint32VarP(&serverArg.port, "port", "p", 8080, "port on which the service will be exposed")
+	serveCmd.Flags().IntVar(&serverArg.poolSize, "pool-size", 4, "maximum number of concurrent /process executions sharing pooled browsers (0 means unlimited, negative disables the pool)")
+	serveCmd.Flags().IntVar(&serverArg.poolMaxUses, "pool-max-uses", 50, "recycle a pooled browser after it served this many executions (0 means never)")
+	serveCmd.Flags().StringVar(&serverArg.jobsDir, "jobs-dir", "", "persist jobs as files in this directory, so results survive restarts (jobs are kept in memory when empty)")
+	serveCmd.Flags().DurationVar(&serverArg.jobsRetention, "jobs-retention", 24*time.Hour, "remove finished jobs after this duration (0 keeps them forever)")
+	serveCmd.Flags().StringVar(&serverArg.keysFile, "auth-keys", "", "file of api keys and their limits, every endpoint requires a valid key when set")
+	serveCmd.Flags().StringVar(&serverArg.policyFile, "policy", "", "policy file restricting the urls, steps, template functions and loops of every pipeline")
+	serveCmd.Flags().StringToStringVar(&serverArg.limitsDefault, "limits-default", nil, "limits of pipelines that do not set their own, e.g. timeout=5m,max_steps=1000,max_loop_iterations=500,max_result_bytes=1048576")
+	serveCmd.Flags().StringToStringVar(&serverArg.limitsCap, "limits-cap", nil, "maximum limits a pipeline may set, same format as --limits-default")
+	serveCmd.Flags().StringVar(&serverArg.artifactsDir, "artifacts-dir", "artifacts", "keep the failure artifacts of jobs and sessions in this directory (empty disables artifacts of API pipelines)")
+	serveCmd.Flags().BoolVar(&serverArg.allowWebhooks, "allow-webhooks", false, "accept webhook hooks in the notify section of pipelines sent to the api (command hooks are only allowed in schedule files)")
+	serveCmd.Flags().StringVar(&serverArg.schedulesDir, "schedules", "", "directory of pipeline files with a `schedule` field to run periodically")
+}
+
+// parseLimits decodes the key=value pairs of a limits flag
+func parseLimits(values map[string]string) (config.Limits, error) {
+	var limits config.Limits
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+		Result:           &limits,
+	})
+	if err != nil {
+		return limits, err
+	}
+	return limits, decoder.Decode(values)
 }
@@ -5,6 +5,15 @@ import "github.com/mxschmitt/playwright-go"
 
 type ExecutionConfig struct {
 	Pipeline Pipeline `mapstructure:"pipeline"`
+	Schedule Schedule `mapstructure:"schedule"`
+}
+
+// Schedule runs a pipeline periodically in serve mode, on a cron expression or every interval
+type Schedule struct {
+	Cron   string `mapstructure:"cron"`   // standard 5 field cron expression or descriptors like @hourly
+	Every  string `mapstructure:"every"`  // interval, e.g. 15m
+	Jitter string `mapstructure:"jitter"` // random delay added before each run, e.g. 30s
+	Keep   int    `mapstructure:"keep"`   // number of results kept, defaults to 10
 }
 
 type Pipeline struct {
@@ -12,13 +21,107 @@ type Pipeline struct {
 	Browser        string                              `mapstructure:"browser"`
 	BrowserParams  playwright.BrowserTypeLaunchOptions `mapstructure:"browser_params"`
 	BrowserOptions playwright.BrowserNewPageOptions    `mapstructure:"browser_page_options"`
+	StorageState   StorageState                        `mapstructure:"storage_state"`
+	RecordHar      HarRecording                        `mapstructure:"record_har"`
+	Trace          TraceRecording                      `mapstructure:"trace"`
+	Video          VideoRecording                      `mapstructure:"video"`
+	ReplayHar      HarReplay                           `mapstructure:"replay_har"`
+	Monitor        Monitor                             `mapstructure:"monitor"`
+	Notify         Notify                              `mapstructure:"notify"`
+	Limits         Limits                              `mapstructure:"limits"`
+	Artifacts      Artifacts                           `mapstructure:"artifacts"`
 	Vars           []Variable                          `mapstructure:"vars"`
 	Steps          []Step                              `mapstructure:"steps"`
 }
 
+// StorageState points to a cookies/localStorage snapshot loaded when the browser context is created
+type StorageState struct {
+	Path     string `mapstructure:"path"`
+	AutoSave bool   `mapstructure:"auto_save"`
+}
+
+// HarRecording writes the network traffic of a run to a HAR file once the browser context is closed
+type HarRecording struct {
+	Path      string `mapstructure:"path"`
+	Content   string `mapstructure:"content"`    // omit, embed or attach
+	Mode      string `mapstructure:"mode"`       // full or minimal
+	URLFilter string `mapstructure:"url_filter"` // glob, only matching requests are recorded
+}
+
+// TraceRecording saves a Playwright trace (screenshots, DOM snapshots and sources) of the run to Path
+// once it ends, open it with `npx playwright show-trace`
+type TraceRecording struct {
+	Path string `mapstructure:"path"`
+	Mode string `mapstructure:"mode"` // on (default) or retain-on-failure
+}
+
+// VideoRecording records a video of every tab of the run into Dir, the files are complete once it ends
+type VideoRecording struct {
+	Dir    string `mapstructure:"dir"`
+	Mode   string `mapstructure:"mode"`  // on (default) or retain-on-failure
+	Width  int    `mapstructure:"width"` // defaults to the viewport scaled down to fit in 800x800
+	Height int    `mapstructure:"height"`
+}
+
+// HarReplay serves responses from a recorded HAR file instead of the network
+type HarReplay struct {
+	Path     string `mapstructure:"path"`
+	URL      string `mapstructure:"url"`       // glob, only matching requests are served from the HAR
+	NotFound string `mapstructure:"not_found"` // abort (default) or fallback to the network
+}
+
+// Monitor compares the output of each run with the previous one, kept in the State file
+type Monitor struct {
+	State    string            `mapstructure:"state"`
+	Keys     map[string]string `mapstructure:"keys"`      // result key of a table -> column identifying its rows
+	DiffOnly bool              `mapstructure:"diff_only"` // emit the diff instead of the output
+}
+
+// Enabled reports whether the run should be compared with the previous one
+func (m Monitor) Enabled() bool {
+	return m.State != ""
+}
+
+// Notify lists the hooks called once a pipeline finishes
+type Notify struct {
+	OnSuccess []Hook `mapstructure:"on_success"`
+	OnFailure []Hook `mapstructure:"on_failure"`
+	OnChange  []Hook `mapstructure:"on_change"` // monitored pipelines whose output changed
+}
+
+// Hook either calls a webhook or runs a local command with the event as JSON on stdin
+type Hook struct {
+	Webhook string            `mapstructure:"webhook"`
+	Method  string            `mapstructure:"method"` // defaults to POST
+	Headers map[string]string `mapstructure:"headers"`
+	Body    string            `mapstructure:"body"` // defaults to the event as JSON
+	Command []string          `mapstructure:"command"`
+	Timeout string            `mapstructure:"timeout"` // defaults to 30s
+}
+
+// Limits bound the resources of a single run, zero values are unlimited
+type Limits struct {
+	Timeout           string `mapstructure:"timeout"` // wall-clock deadline of the run, e.g. 5m
+	MaxSteps          int    `mapstructure:"max_steps"`
+	MaxLoopIterations int    `mapstructure:"max_loop_iterations"`
+	MaxResultBytes    int    `mapstructure:"max_result_bytes"` // size of the result encoded as JSON
+}
+
+// Artifacts captures the page of failing steps (screenshot, HTML, url and console log) into Dir
+type Artifacts struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Dir     string `mapstructure:"dir"`   // defaults to ./artifacts, the API server keeps them per job or session
+	Trace   bool   `mapstructure:"trace"` // also save a Playwright trace of the run up to each failure
+}
+
+// IsEnabled reports whether failures should be captured, setting a dir enables them
+func (a Artifacts) IsEnabled() bool {
+	return a.Enabled || a.Dir != ""
+}
+
 type Variable struct {
 	Name         string `mapstructure:"name"`
-	Value        string `mapstructure:"value"`
+	Value        any    `mapstructure:"value"`
 	Random       string `mapstructure:"random"`
 	RandomChars  string `mapstructure:"random_chars"`
 	RandomLength int    `mapstructure:"random_length"`
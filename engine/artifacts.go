@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/artifacts"
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/middlewares"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+const defaultArtifactsDir = "artifacts"
+
+// newArtifacts returns the recorder of the failure artifacts of a run, nil when they are disabled.
+// Runs recording their own trace (traced) leave it out of the artifacts, Playwright records one trace per context.
+// Without an artifacts directory in the options (CLI and schedules), each run gets its own sub directory of the
+// configured one, so runs do not overwrite the artifacts of each other
+func (o *options) newArtifacts(cfg config.Artifacts, traced bool) *artifacts.Recorder {
+	if !cfg.IsEnabled() {
+		return nil
+	}
+	dir := cfg.Dir
+	if o.artifactsDir != nil {
+		if dir = *o.artifactsDir; dir == "" {
+			return nil
+		}
+	}
+	if dir == "" {
+		dir = defaultArtifactsDir
+	}
+	if o.artifactsDir == nil {
+		dir = filepath.Join(dir, runDirName(time.Now()))
+	}
+	slog.Info("failure artifacts of the run are kept in", slog.String("dir", dir))
+	return artifacts.New(dir, cfg.Trace && !traced)
+}
+
+// bindArtifacts captures the failures of the steps executed with vars, rec must be closed before the page
+func bindArtifacts(rec *artifacts.Recorder, page playwright.Page, vars utils.Vars) error {
+	if rec == nil {
+		return nil
+	}
+	if err := rec.Attach(page.Context()); err != nil {
+		return err
+	}
+	middlewares.BindArtifacts(vars, rec)
+	return nil
+}
+
+// runDirName names the artifacts directory of a run, names sort by start time
+func runDirName(start time.Time) string {
+	return start.UTC().Format("20060102-150405") + "-" + uuid.NewString()[:8]
+}
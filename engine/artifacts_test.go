@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fmotalleb/scrapper-go/config"
+)
+
+func TestNewArtifactsDir(t *testing.T) {
+	enabled := config.Artifacts{Enabled: true, Dir: "out"}
+	first := newOptions(nil).newArtifacts(enabled, false)
+	second := newOptions(nil).newArtifacts(enabled, false)
+	if filepath.Dir(first.Dir()) != "out" || filepath.Dir(second.Dir()) != "out" {
+		t.Errorf("artifacts of runs in %q and %q, expected sub directories of out", first.Dir(), second.Dir())
+	}
+	if first.Dir() == second.Dir() {
+		t.Errorf("two runs share the artifacts directory %q", first.Dir())
+	}
+	if dir := newOptions(nil).newArtifacts(config.Artifacts{Enabled: true}, false).Dir(); filepath.Dir(dir) != defaultArtifactsDir {
+		t.Errorf("artifacts without dir in %q, expected a sub directory of %s", dir, defaultArtifactsDir)
+	}
+	if dir := newOptions([]Option{WithArtifactsDir("jobs/1")}).newArtifacts(enabled, false).Dir(); dir != "jobs/1" {
+		t.Errorf("artifacts of a job in %q, expected the directory of the job", dir)
+	}
+	if rec := newOptions([]Option{WithArtifactsDir("")}).newArtifacts(enabled, false); rec != nil {
+		t.Error("artifacts recorded with an empty artifacts directory")
+	}
+	if rec := newOptions(nil).newArtifacts(config.Artifacts{}, false); rec != nil {
+		t.Error("artifacts recorded while disabled")
+	}
+}
+
+func TestRunDirName(t *testing.T) {
+	start := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	name := runDirName(start)
+	if len(name) != len("20260102-150405-")+8 || name[:16] != "20260102-150405-" {
+		t.Errorf("runDirName = %q, expected 20260102-150405-<suffix>", name)
+	}
+}
@@ -3,9 +3,10 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/mxschmitt/playwright-go"
@@ -14,10 +15,26 @@ import (
 	"github.com/fmotalleb/scrapper-go/engine/middlewares"
 	"github.com/fmotalleb/scrapper-go/engine/steps"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/metrics"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/telemetry"
+	"github.com/fmotalleb/scrapper-go/utils"
 )
 
 // ExecuteConfig loaded from cli or api
-func ExecuteConfig(ctx context.Context, config config.ExecutionConfig) (map[string]any, error) {
+func ExecuteConfig(ctx context.Context, config config.ExecutionConfig, opts ...Option) (result map[string]any, err error) {
+	options := newOptions(opts)
+	defer observeRun(ctx, time.Now(), &err)
+	ctx, span := startSpan(ctx, "pipeline", config.Pipeline, len(config.Pipeline.Steps))
+	defer func() {
+		telemetry.End(span, err)
+	}()
+	if options.trace != nil {
+		defer func() {
+			options.trace.Finish(err)
+		}()
+	}
+
 	// Initialize Variables
 	vars, err := initializeVariables(config.Pipeline.Vars)
 	if err != nil {
@@ -27,93 +44,160 @@ func ExecuteConfig(ctx context.Context, config config.ExecutionConfig) (map[stri
 	if len(config.Pipeline.Steps) == 0 {
 		return nil, fmt.Errorf("pipeline has no steps, preflight check failed")
 	}
+	if config.Pipeline, err = options.confinePaths(config.Pipeline); err != nil {
+		return nil, fmt.Errorf("preflight check failed: %w", err)
+	}
 
-	// Start Playwright
-	pw, err := playwright.Run()
+	// Build Steps before acquiring a browser, so invalid pipelines fail fast
+	stepList, err := steps.BuildSteps(config.Pipeline.Steps)
 	if err != nil {
-		slog.Error("could not start Playwright", log.ErrVal(err))
-		return nil, fmt.Errorf("playwright startup failed: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if err := pw.Stop(); err != nil {
-			slog.Warn("failed to stop Playwright session", log.ErrVal(err))
-		}
-	}()
 
-	// Stop Playwright when context is canceled
-	killWithContext(ctx, pw)
+	runCtx, cancel, timeout, err := options.bindLimits(ctx, vars, config.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	out, err := execute(runCtx, config, options, stepList, vars)
+	return out, timeoutError(err, ctx, runCtx, timeout)
+}
 
-	slog.Info("Playwright initialized")
+// execute runs the built steps of a pipeline on a new page, the paths of its recordings are added
+// to the output metadata (even when it fails)
+func execute(ctx context.Context, config config.ExecutionConfig, options *options, stepList []steps.Step, vars utils.Vars) (out map[string]any, err error) {
+	rec, err := options.newRecordings(config.Pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("preflight check failed: %w", err)
+	}
 
-	// Launch Browser
-	browser, err := launchBrowser(pw, config.Pipeline.Browser, config.Pipeline.BrowserParams)
+	// Acquire Browser
+	browser, release, err := options.provider.Acquire(ctx, config.Pipeline.Browser, config.Pipeline.BrowserParams)
 	if err != nil {
-		slog.Error("could not launch browser", log.ErrVal(err))
 		return nil, err
 	}
-	defer func() {
-		if err := browser.Close(); err != nil {
-			slog.Error("failed to close browser", log.ErrVal(err))
-		}
-	}()
-
-	// Handle KeepRunning at the end
-	defer handleKeepRunning(config.Pipeline.KeepRunning)
+	defer release()
 
 	// Create Page
-	page, err := browser.NewPage(config.Pipeline.BrowserOptions)
+	page, err := newPage(browser, config.Pipeline, rec)
 	if err != nil {
-		slog.Error("could not create page", log.ErrVal(err))
-		return nil, fmt.Errorf("page creation failed: %w", err)
+		return nil, err
 	}
+	defer func() {
+		rec.stop(err != nil)
+		closePage(page, config.Pipeline)
+		out = withRecordings(out, rec.finish(err != nil))
+	}()
 
-	// Build Steps
-	stepList, err := steps.BuildSteps(config.Pipeline.Steps)
-	if err != nil {
+	// Handle KeepRunning at the end, while the page is still open
+	defer handleKeepRunning(config.Pipeline.KeepRunning)
+
+	// Capture failing steps, the recorder is closed before the page
+	recorder := options.newArtifacts(config.Pipeline.Artifacts, rec.traced())
+	if err := bindArtifacts(recorder, page, vars); err != nil {
 		return nil, err
 	}
+	defer recorder.Close()
+
+	// Close the browser context when ctx is canceled, so a shared browser stays usable.
+	// The trace is saved first, canceled runs failed
+	stop := context.AfterFunc(ctx, func() {
+		rec.stop(true)
+		_ = page.Context().Close()
+	})
+	defer stop()
 
 	// Execute Steps
 	result := make(map[string]any)
-	for _, step := range stepList {
-		if err = middlewares.HandleStep(page, step, vars, result); err != nil {
-			return nil, err
-		}
+	bindResults(vars, result)
+	options.bindFiles(vars)
+	options.bindFunctions(vars)
+	middlewares.BindContextFactory(vars, newContextFactory(browser, config.Pipeline, rec))
+	if options.trace != nil {
+		middlewares.BindTrace(vars, options.trace)
 	}
-	out := make(map[string]any, len(result)/2)
-	for k, v := range result {
-		if !strings.HasPrefix(k, "__$") {
-			out[k] = v
+	bindSpan(ctx, vars)
+	options.reportProgress(0, len(stepList))
+	for index, step := range stepList {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("execution canceled: %w", err)
+		}
+		if err := middlewares.HandleStep(page, step, vars, result); err != nil {
+			return nil, err
 		}
+		options.reportProgress(index+1, len(stepList))
 	}
+	out = utils.PublicResults(result)
 	slog.Debug("engine state", slog.Any("vars_snapshot", vars.Snapshot()), slog.Any("result", out))
 	slog.Info("Execution finished")
 	return out, nil
 }
 
-func ExecuteStream(ctx context.Context, config config.ExecutionConfig, pipeline <-chan []config.Step) (<-chan map[string]any, error) {
+// Batch is a list of steps sent to a stream, Ctx carries the span of the request that sent them (if any)
+type Batch struct {
+	Ctx   context.Context
+	Steps []config.Step
+}
+
+// ExecuteStream runs batches of steps received from pipeline on a single page, see ExecuteBatches
+func ExecuteStream(ctx context.Context, config config.ExecutionConfig, pipeline <-chan []config.Step, opts ...Option) (<-chan map[string]any, error) {
+	batches := make(chan Batch)
+	resultChan, err := ExecuteBatches(ctx, config, batches, opts...)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(batches)
+		for steps := range pipeline {
+			batches <- Batch{Ctx: ctx, Steps: steps}
+		}
+	}()
+	return resultChan, nil
+}
+
+// ExecuteBatches runs batches of steps received from pipeline on a single page, each batch sends one result.
+// The limits timeout bounds the whole stream, the other limits apply to each batch
+func ExecuteBatches(ctx context.Context, config config.ExecutionConfig, pipeline <-chan Batch, opts ...Option) (<-chan map[string]any, error) {
+	options := newOptions(opts)
 	vars, err := initializeVariables(config.Pipeline.Vars)
 	if err != nil {
 		slog.Error("failed to load variables", log.ErrVal(err))
 		return nil, fmt.Errorf("preflight check failed: %w", err)
 	}
+	limits, timeout, err := options.resolveLimits(config.Pipeline.Limits)
+	if err != nil {
+		return nil, fmt.Errorf("preflight check failed: %w", err)
+	}
+	if config.Pipeline, err = options.confinePaths(config.Pipeline); err != nil {
+		return nil, fmt.Errorf("preflight check failed: %w", err)
+	}
+	options.bindFiles(vars)
+	options.bindFunctions(vars)
+	rec, err := options.newRecordings(config.Pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("preflight check failed: %w", err)
+	}
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
 
 	// Start Playwright
 	pw, err := playwright.Run()
 	if err != nil {
 		slog.Error("could not start Playwright", log.ErrVal(err))
+		cancel()
 		return nil, fmt.Errorf("playwright startup failed: %w", err)
 	}
 
-	// Stop Playwright when context is canceled
-	killWithContext(ctx, pw)
-
 	slog.Info("Playwright initialized")
 
 	// Launch Browser
-	browser, err := launchBrowser(pw, config.Pipeline.Browser, config.Pipeline.BrowserParams)
+	browser, err := LaunchBrowser(pw, config.Pipeline.Browser, config.Pipeline.BrowserParams)
 	if err != nil {
 		slog.Error("could not launch browser", log.ErrVal(err))
+		_ = pw.Stop()
+		cancel()
 		return nil, err
 	}
 
@@ -121,60 +205,166 @@ func ExecuteStream(ctx context.Context, config config.ExecutionConfig, pipeline
 	defer handleKeepRunning(config.Pipeline.KeepRunning)
 
 	// Create Page
-	page, err := browser.NewPage(config.Pipeline.BrowserOptions)
+	page, err := newPage(browser, config.Pipeline, rec)
 	if err != nil {
-		slog.Error("could not create page", log.ErrVal(err))
-		return nil, fmt.Errorf("page creation failed: %w", err)
+		_ = pw.Stop()
+		cancel()
+		return nil, err
 	}
 
+	middlewares.BindContextFactory(vars, newContextFactory(browser, config.Pipeline, rec))
+
+	recorder := options.newArtifacts(config.Pipeline.Artifacts, rec.traced())
+	if err := bindArtifacts(recorder, page, vars); err != nil {
+		closePage(page, config.Pipeline)
+		_ = pw.Stop()
+		cancel()
+		return nil, err
+	}
+
+	// Save the storage state, flush the HAR and the recordings and stop Playwright when context is canceled or timed out
+	var failed atomic.Bool
+	killWithContext(ctx, pw, recorder.Close, func() {
+		rec.stop(failed.Load())
+		closePage(page, config.Pipeline)
+		if meta := rec.finish(failed.Load()); len(meta) > 0 {
+			slog.Info("stream recordings saved", slog.Any("recordings", meta))
+		}
+	}, cancel)
+
 	resultChan := make(chan map[string]any)
 
 	go func() {
-		for i := range pipeline {
+		for batch := range pipeline {
+			i := batch.Steps
+			batchCtx := batch.Ctx
+			if batchCtx == nil {
+				batchCtx = ctx
+			}
+			// Every batch counts as a run in the metrics
+			start := time.Now()
+			batchCtx, span := startSpan(batchCtx, "session request", config.Pipeline, len(i))
 			result := make(map[string]any)
+			bindResults(vars, result)
+			bindSpan(batchCtx, vars)
+			middlewares.BindLimits(vars, middlewares.NewRunLimits(limits.MaxSteps, limits.MaxLoopIterations, limits.MaxResultBytes))
 			stepList, err := steps.BuildSteps(i)
 			if err != nil {
 				slog.Error("failed to build step", slog.Any("step", i))
+				observeRun(batchCtx, start, &err)
+				telemetry.End(span, err)
 				continue
 			}
+			var errs []error
 			for _, step := range stepList {
-				if err = middlewares.HandleStep(page, step, vars, result); err != nil {
+				if err = middlewares.HandleStep(page, step, vars, result); middlewares.IsLimitExceeded(err) {
+					slog.Error("batch stopped by its limits", slog.Any("step", i), log.ErrVal(err))
+					errs = append(errs, err)
+					break
+				} else if err != nil {
 					slog.Error("failed to handle step", slog.Any("step", i))
+					errs = append(errs, err)
 					continue
 				}
 			}
-			out := make(map[string]any, len(result)/2)
-			for k, v := range result {
-				if !strings.HasPrefix(k, "__$") {
-					out[k] = v
-				}
+			if len(errs) > 0 {
+				failed.Store(true)
 			}
-			resultChan <- out
+			batchErr := errors.Join(errs...)
+			observeRun(batchCtx, start, &batchErr)
+			telemetry.End(span, batchErr)
+			resultChan <- utils.PublicResults(result)
 		}
 	}()
 
 	return resultChan, nil
 }
 
-func killWithContext(ctx context.Context, pw *playwright.Playwright) {
+// killWithContext stops Playwright once ctx is done, cleanups run before stopping it
+func killWithContext(ctx context.Context, pw *playwright.Playwright, cleanups ...func()) {
 	go func() {
 		<-ctx.Done()
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
 		_ = pw.Stop()
 	}()
 }
 
-// launchBrowser initializes the correct browser based on config
-func launchBrowser(pw *playwright.Playwright, browserType string, params playwright.BrowserTypeLaunchOptions) (playwright.Browser, error) {
+// newPage opens the first tab of a pipeline, further tabs are opened by steps
+func newPage(browser playwright.Browser, pipeline config.Pipeline, rec *recordings) (*steps.Tabs, error) {
+	options, err := applyHarRecording(applyStorageState(pipeline.BrowserOptions, pipeline.StorageState), pipeline.RecordHar)
+	if err != nil {
+		return nil, err
+	}
+	page, err := browser.NewPage(applyPolicy(rec.applyVideoRecording(options)))
+	if err != nil {
+		slog.Error("could not create page", log.ErrVal(err))
+		return nil, fmt.Errorf("page creation failed: %w", err)
+	}
+	if err := replayHar(page.Context(), pipeline.ReplayHar); err != nil {
+		_ = page.Close()
+		return nil, err
+	}
+	if err := policy.Guard(page.Context()); err != nil {
+		_ = page.Close()
+		return nil, err
+	}
+	if err := rec.start(page); err != nil {
+		_ = page.Close()
+		return nil, err
+	}
+	return steps.NewTabs(page), nil
+}
+
+// newContextFactory opens the contexts of isolated parallel loops with the browser options, video recording
+// and HAR replay of the pipeline. The HAR recording, storage state file and trace stay with the first context
+func newContextFactory(browser playwright.Browser, pipeline config.Pipeline, rec *recordings) middlewares.ContextFactory {
+	return func(state *playwright.StorageState) (playwright.BrowserContext, error) {
+		options := pipeline.BrowserOptions
+		options.StorageStatePath = nil
+		options.StorageState = state.ToOptionalStorageState()
+		ctx, err := browser.NewContext(playwright.BrowserNewContextOptions(applyPolicy(rec.applyVideoRecording(options))))
+		if err != nil {
+			return nil, fmt.Errorf("context creation failed: %w", err)
+		}
+		if err := replayHar(ctx, pipeline.ReplayHar); err != nil {
+			_ = ctx.Close()
+			return nil, err
+		}
+		return ctx, nil
+	}
+}
+
+// closePage saves the storage state and closes the browser context of the pipeline,
+// a recorded HAR file is only written once its context is closed
+func closePage(page *steps.Tabs, pipeline config.Pipeline) {
+	autoSaveStorageState(page, pipeline.StorageState)
+	if err := page.Context().Close(); err != nil {
+		slog.Warn("failed to close browser context", log.ErrVal(err))
+	}
+}
+
+// LaunchBrowser initializes the correct browser based on config
+func LaunchBrowser(pw *playwright.Playwright, browserType string, params playwright.BrowserTypeLaunchOptions) (playwright.Browser, error) {
+	var kind playwright.BrowserType
 	switch browserType {
 	case "chromium":
-		return pw.Chromium.Launch(params)
+		kind = pw.Chromium
 	case "firefox":
-		return pw.Firefox.Launch(params)
+		kind = pw.Firefox
 	case "webkit":
-		return pw.WebKit.Launch(params)
+		kind = pw.WebKit
 	default:
 		return nil, fmt.Errorf("unsupported browser type: %s", browserType)
 	}
+	browser, err := kind.Launch(params)
+	if err != nil {
+		metrics.BrowserLaunches.Inc(browserType, metrics.OutcomeFailure)
+		return nil, err
+	}
+	metrics.BrowserLaunches.Inc(browserType, metrics.OutcomeSuccess)
+	return browser, nil
 }
 
 // handleKeepRunning ensures the process stays alive for a set duration if needed
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"path/filepath"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// filesDir is the directory the files of the pipeline are confined to, when the artifacts directory is set.
+// It is empty when files are disabled
+func (o *options) filesDir() (dir string, confined bool) {
+	if o.artifactsDir == nil {
+		return "", false
+	}
+	if *o.artifactsDir == "" {
+		return "", true
+	}
+	return filepath.Join(*o.artifactsDir, "files"), true
+}
+
+// bindFiles confines the files of the steps executed with vars
+func (o *options) bindFiles(vars utils.Vars) {
+	if dir, confined := o.filesDir(); confined {
+		steps.BindFilesDir(vars, dir)
+	}
+}
+
+// confinePaths resolves the files the pipeline loads and saves in the files directory
+func (o *options) confinePaths(pipeline config.Pipeline) (config.Pipeline, error) {
+	dir, confined := o.filesDir()
+	if !confined {
+		return pipeline, nil
+	}
+	var err error
+	resolve := func(path string) string {
+		if err != nil || path == "" {
+			return path
+		}
+		path, err = steps.ResolveFileIn(dir, path)
+		return path
+	}
+	pipeline.StorageState.Path = resolve(pipeline.StorageState.Path)
+	pipeline.RecordHar.Path = resolve(pipeline.RecordHar.Path)
+	pipeline.ReplayHar.Path = resolve(pipeline.ReplayHar.Path)
+	// Pointers are shared with the caller's config, they are replaced rather than updated
+	if path := pipeline.BrowserOptions.StorageStatePath; path != nil {
+		pipeline.BrowserOptions.StorageStatePath = playwright.String(resolve(*path))
+	}
+	if path := pipeline.BrowserOptions.RecordHarPath; path != nil {
+		pipeline.BrowserOptions.RecordHarPath = playwright.String(resolve(*path))
+	}
+	if video := pipeline.BrowserOptions.RecordVideo; video != nil && video.Dir != nil {
+		pipeline.BrowserOptions.RecordVideo = &playwright.RecordVideo{Dir: playwright.String(resolve(*video.Dir)), Size: video.Size}
+	}
+	return pipeline, err
+}
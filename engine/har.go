@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+)
+
+// applyHarRecording enables HAR recording on the page options, the file is written when the context is closed
+func applyHarRecording(options playwright.BrowserNewPageOptions, rec config.HarRecording) (playwright.BrowserNewPageOptions, error) {
+	if rec.Path == "" {
+		return options, nil
+	}
+	options.RecordHarPath = playwright.String(rec.Path)
+	switch rec.Content {
+	case "":
+	case "omit", "embed", "attach":
+		options.RecordHarContent = (*playwright.HarContentPolicy)(playwright.String(rec.Content))
+	default:
+		return options, fmt.Errorf("unknown record_har content %q, expected omit, embed or attach", rec.Content)
+	}
+	switch rec.Mode {
+	case "":
+	case "full", "minimal":
+		options.RecordHarMode = (*playwright.HarMode)(playwright.String(rec.Mode))
+	default:
+		return options, fmt.Errorf("unknown record_har mode %q, expected full or minimal", rec.Mode)
+	}
+	if rec.URLFilter != "" {
+		options.RecordHarURLFilter = rec.URLFilter
+	}
+	slog.Debug("recording HAR", slog.String("path", rec.Path))
+	return options, nil
+}
+
+// replayHar serves matching requests of the context from the HAR file, requests missing from it
+// are aborted unless not_found is set to fallback
+func replayHar(ctx playwright.BrowserContext, rep config.HarReplay) error {
+	if rep.Path == "" {
+		return nil
+	}
+	if _, err := os.Stat(rep.Path); err != nil {
+		return fmt.Errorf("replay_har file is not accessible: %w", err)
+	}
+	options := playwright.BrowserContextRouteFromHAROptions{}
+	switch rep.NotFound {
+	case "":
+	case "abort", "fallback":
+		options.NotFound = (*playwright.HarNotFound)(playwright.String(rep.NotFound))
+	default:
+		return fmt.Errorf("unknown replay_har not_found %q, expected abort or fallback", rep.NotFound)
+	}
+	if rep.URL != "" {
+		options.URL = rep.URL
+	}
+	slog.Debug("replaying HAR", slog.String("path", rep.Path))
+	if err := ctx.RouteFromHAR(rep.Path, options); err != nil {
+		return fmt.Errorf("failed to replay HAR: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/middlewares"
+	"github.com/fmotalleb/scrapper-go/metrics"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// resolveLimits applies the default and cap limits of the options to the limits of a pipeline
+func (o *options) resolveLimits(limits config.Limits) (config.Limits, time.Duration, error) {
+	timeout, err := resolveTimeout(limits.Timeout, o.defaultLimits.Timeout, o.capLimits.Timeout)
+	if err != nil {
+		return limits, 0, err
+	}
+	limits.MaxSteps = resolveLimit(limits.MaxSteps, o.defaultLimits.MaxSteps, o.capLimits.MaxSteps)
+	limits.MaxLoopIterations = resolveLimit(limits.MaxLoopIterations, o.defaultLimits.MaxLoopIterations, o.capLimits.MaxLoopIterations)
+	limits.MaxResultBytes = resolveLimit(limits.MaxResultBytes, o.defaultLimits.MaxResultBytes, o.capLimits.MaxResultBytes)
+	return limits, timeout, nil
+}
+
+func resolveLimit(value, def, limit int) int {
+	if value <= 0 {
+		value = def
+	}
+	if limit > 0 && (value <= 0 || value > limit) {
+		value = limit
+	}
+	return value
+}
+
+func resolveTimeout(value, def, limit string) (time.Duration, error) {
+	var durations [3]time.Duration
+	for i, str := range []string{value, def, limit} {
+		if str == "" {
+			continue
+		}
+		d, err := time.ParseDuration(str)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid limits timeout %q, expected a positive duration like 5m", str)
+		}
+		durations[i] = d
+	}
+	return time.Duration(resolveLimit(int(durations[0]), int(durations[1]), int(durations[2]))), nil
+}
+
+// bindLimits attaches the limits of a run to its variables and its timeout to the returned context
+func (o *options) bindLimits(ctx context.Context, vars utils.Vars, pipeline config.Pipeline) (context.Context, context.CancelFunc, time.Duration, error) {
+	limits, timeout, err := o.resolveLimits(pipeline.Limits)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("preflight check failed: %w", err)
+	}
+	middlewares.BindLimits(vars, middlewares.NewRunLimits(limits.MaxSteps, limits.MaxLoopIterations, limits.MaxResultBytes))
+	if timeout == 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, 0, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout, nil
+}
+
+// timeoutError replaces the error of a run that was stopped by its own timeout, rather than by its caller
+func timeoutError(err error, parent, run context.Context, timeout time.Duration) error {
+	if err == nil || parent.Err() != nil || !errors.Is(run.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	metrics.Timeouts.Inc(metrics.TimeoutRun)
+	return fmt.Errorf("%w: run took longer than %s (timeout)", middlewares.ErrLimitExceeded, timeout)
+}
@@ -0,0 +1,26 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fmotalleb/scrapper-go/engine/middlewares"
+	"github.com/fmotalleb/scrapper-go/metrics"
+)
+
+// observeRun counts a run of ExecuteConfig by its outcome, err points to the error it returns
+func observeRun(ctx context.Context, start time.Time, err *error) {
+	outcome := metrics.OutcomeSuccess
+	switch {
+	case *err == nil:
+	case middlewares.IsLimitExceeded(*err):
+		outcome = metrics.OutcomeLimitExceeded
+	case errors.Is(ctx.Err(), context.Canceled):
+		outcome = metrics.OutcomeCanceled
+	default:
+		outcome = metrics.OutcomeFailure
+	}
+	metrics.Runs.Inc(outcome)
+	metrics.RunDuration.Observe(metrics.Since(start), outcome)
+}
@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// ErrLimitExceeded ends a run that went over one of its limits, neither `try` nor `on-error` can catch it
+var ErrLimitExceeded = errors.New("run limit exceeded")
+
+// limitsVar holds the limits of the run, hidden from templates
+const limitsVar = utils.HiddenVarPrefix + "limits"
+
+// RunLimits tracks what a run consumed against its limits, zero limits are unlimited.
+// A nil RunLimits has no limits
+type RunLimits struct {
+	maxSteps          int64
+	maxLoopIterations int
+	maxResultBytes    int64
+	steps             *atomic.Int64
+	// resultBytes is nil in parallel iterations, their results are counted once merged
+	resultBytes *atomic.Int64
+}
+
+func NewRunLimits(maxSteps, maxLoopIterations, maxResultBytes int) *RunLimits {
+	return &RunLimits{
+		maxSteps:          int64(maxSteps),
+		maxLoopIterations: maxLoopIterations,
+		maxResultBytes:    int64(maxResultBytes),
+		steps:             new(atomic.Int64),
+		resultBytes:       new(atomic.Int64),
+	}
+}
+
+// BindLimits attaches the limits to the variables of a run, scopes cloned from them share the limits
+func BindLimits(v utils.Vars, limits *RunLimits) {
+	v.SetOnce(limitsVar, limits)
+}
+
+// IsLimitExceeded reports whether the error ends the run because of its limits
+func IsLimitExceeded(err error) bool {
+	return errors.Is(err, ErrLimitExceeded)
+}
+
+func limitsOf(v utils.Vars) *RunLimits {
+	limits, _ := v.GetOr(limitsVar, nil).(*RunLimits)
+	return limits
+}
+
+// countStep is called for every step handled, nested ones included
+func (l *RunLimits) countStep() error {
+	if l == nil || l.maxSteps == 0 {
+		return nil
+	}
+	if l.steps.Add(1) > l.maxSteps {
+		return fmt.Errorf("%w: executed more than %d steps (max_steps)", ErrLimitExceeded, l.maxSteps)
+	}
+	return nil
+}
+
+func (l *RunLimits) checkLoop(iterations int) error {
+	if l == nil || l.maxLoopIterations == 0 || iterations <= l.maxLoopIterations {
+		return nil
+	}
+	return fmt.Errorf("%w: loop runs more than %d iterations (max_loop_iterations)", ErrLimitExceeded, l.maxLoopIterations)
+}
+
+// boundsLoops reports whether loops are limited, so while/until loops may omit max-iterations
+func (l *RunLimits) boundsLoops() bool {
+	return l != nil && l.maxLoopIterations > 0
+}
+
+// addResult counts the JSON size of a value stored in the result map
+func (l *RunLimits) addResult(value any) error {
+	if l == nil || l.maxResultBytes == 0 || l.resultBytes == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		// Values that cannot be encoded are reported when formatting the output
+		return nil
+	}
+	if total := l.resultBytes.Add(int64(len(data))); total > l.maxResultBytes {
+		return fmt.Errorf("%w: result is larger than %d bytes (max_result_bytes)", ErrLimitExceeded, l.maxResultBytes)
+	}
+	return nil
+}
+
+// forIteration shares the step counter with a parallel iteration, leaving its results uncounted
+func (l *RunLimits) forIteration() *RunLimits {
+	if l == nil {
+		return nil
+	}
+	iteration := *l
+	iteration.resultBytes = nil
+	return &iteration
+}
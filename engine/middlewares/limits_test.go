@@ -0,0 +1,157 @@
+package middlewares
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// handleSteps runs the steps through the middlewares without a page, only steps that do not use it can be tested
+func handleSteps(t *testing.T, list []config.Step, v utils.Vars, r map[string]any) error {
+	t.Helper()
+	built, err := steps.BuildSteps(list)
+	if err != nil {
+		t.Fatalf("BuildSteps failed: %v", err)
+	}
+	for _, step := range built {
+		if err := HandleStep(nil, step, v, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nops(count int) []config.Step {
+	list := make([]config.Step, count)
+	for i := range list {
+		list[i] = config.Step{"nop": "step"}
+	}
+	return list
+}
+
+// nested is the body of a block as decoded from a pipeline file
+func nested(list ...config.Step) []any {
+	body := make([]any, len(list))
+	for i, step := range list {
+		body[i] = map[string]any(step)
+	}
+	return body
+}
+
+func TestRunLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   *RunLimits
+		steps    []config.Step
+		exceeded string
+	}{
+		{name: "unlimited", steps: nops(20)},
+		{name: "zero limits", limits: NewRunLimits(0, 0, 0), steps: nops(20)},
+		{name: "steps within limit", limits: NewRunLimits(3, 0, 0), steps: nops(3)},
+		{name: "steps over limit", limits: NewRunLimits(3, 0, 0), steps: nops(4), exceeded: "max_steps"},
+		{
+			name:     "nested steps are counted",
+			limits:   NewRunLimits(5, 0, 0),
+			steps:    []config.Step{{"loop": []any{1, 2, 3}, "steps": nested(nops(1)...)}, {"loop": []any{1, 2}, "steps": nested(nops(1)...)}},
+			exceeded: "max_steps",
+		},
+		{name: "loop within limit", limits: NewRunLimits(0, 3, 0), steps: []config.Step{{"loop": []any{1, 2, 3}, "steps": nested(nops(1)...)}}},
+		{name: "loop over limit", limits: NewRunLimits(0, 3, 0), steps: []config.Step{{"loop": 4, "steps": nested(nops(1)...)}}, exceeded: "max_loop_iterations"},
+		{
+			name:     "while loop bounded by the limits",
+			limits:   NewRunLimits(0, 5, 0),
+			steps:    []config.Step{{"while": "true", "steps": nested(nops(1)...)}},
+			exceeded: "max_loop_iterations",
+		},
+		{
+			name:   "result within limit",
+			limits: NewRunLimits(0, 0, 64),
+			steps:  []config.Step{{"nop": "short", "set-var": "text"}},
+		},
+		{
+			name:     "result over limit",
+			limits:   NewRunLimits(0, 0, 64),
+			steps:    []config.Step{{"loop": 10, "steps": nested(config.Step{"nop": "a longer text value", "set-var": "text"})}},
+			exceeded: "max_result_bytes",
+		},
+		{
+			name:     "try does not catch limits",
+			limits:   NewRunLimits(2, 0, 0),
+			steps:    []config.Step{{"try": nested(nops(3)...), "catch": nested(nops(1)...)}},
+			exceeded: "max_steps",
+		},
+		{
+			name:     "omit cannot remove the limits",
+			limits:   NewRunLimits(0, 3, 0),
+			steps:    []config.Step{{"omit": limitsVar, "on-error": "ignore"}, {"loop": 10, "steps": nested(nops(1)...)}},
+			exceeded: "max_loop_iterations",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := make(utils.Vars)
+			if tt.limits != nil {
+				BindLimits(v, tt.limits)
+			}
+			err := handleSteps(t, tt.steps, v, make(map[string]any))
+			if tt.exceeded == "" {
+				if err != nil {
+					t.Fatalf("steps failed: %v", err)
+				}
+				return
+			}
+			if !IsLimitExceeded(err) || !strings.Contains(err.Error(), tt.exceeded) {
+				t.Fatalf("steps = %v, expected %s to be exceeded", err, tt.exceeded)
+			}
+		})
+	}
+}
+
+// TestOmitLimits checks a pipeline cannot remove the limits of its run
+func TestOmitLimits(t *testing.T) {
+	limits := NewRunLimits(0, 3, 0)
+	v := make(utils.Vars)
+	BindLimits(v, limits)
+	if err := handleSteps(t, []config.Step{{"omit": limitsVar}}, v, make(map[string]any)); err == nil {
+		t.Fatal("omit of the limits succeeded, expected an error")
+	}
+	if limitsOf(v) != limits {
+		t.Fatal("omit removed the limits")
+	}
+	for _, name := range []string{limitsVar, utils.HiddenVarPrefix + "files_dir"} {
+		step := config.Step{"nop": "x", "set-var": name}
+		if err := handleSteps(t, []config.Step{step}, v, make(map[string]any)); err == nil {
+			t.Errorf("set-var %q succeeded, expected an error", name)
+		}
+		loop := config.Step{"loop": 1, "loop-key": name, "steps": nested(nops(1)...)}
+		if err := handleSteps(t, []config.Step{loop}, v, make(map[string]any)); err == nil {
+			t.Errorf("loop-key %q succeeded, expected an error", name)
+		}
+	}
+	if limitsOf(v) != limits {
+		t.Error("the limits were replaced")
+	}
+}
+
+func TestRunLimitsForIteration(t *testing.T) {
+	limits := NewRunLimits(3, 0, 10)
+	iteration := limits.forIteration()
+	for range 3 {
+		if err := iteration.countStep(); err != nil {
+			t.Fatalf("countStep failed: %v", err)
+		}
+	}
+	if err := limits.countStep(); !IsLimitExceeded(err) {
+		t.Errorf("countStep of the run = %v, expected the steps of the iteration to be shared", err)
+	}
+	if err := iteration.addResult(strings.Repeat("x", 100)); err != nil {
+		t.Errorf("addResult of an iteration = %v, expected its results to be counted once merged", err)
+	}
+	var unlimited *RunLimits
+	if unlimited.forIteration() != nil || unlimited.countStep() != nil || unlimited.boundsLoops() {
+		t.Error("nil limits must be unlimited")
+	}
+}
@@ -22,8 +22,9 @@ func errorHandler(p playwright.Page, s steps.Step, v utils.Vars, r map[string]an
 	}
 
 	err := next(p, s, v, r)
-	if err == nil {
-		return nil
+	if err == nil || steps.IsLoopControl(err) || IsLimitExceeded(err) {
+		// break/continue must reach their loop regardless of on-error, exceeded limits must end the run
+		return err
 	}
 
 	if errMode, ok := s.GetConfig()["on-error"].(string); ok {
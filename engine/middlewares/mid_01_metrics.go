@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"time"
+
+	playwright "github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/metrics"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+func init() {
+	registerMiddleware(measure)
+}
+
+// measure implements Middleware.
+// It counts the steps by type and outcome and observes their duration, blocks include their nested steps.
+// Errors are counted before on-error handles them
+func measure(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next execFunc) error {
+	start := time.Now()
+	err := next(p, s, v, r)
+	stepType := steps.TypeOf(s.GetConfig())
+	outcome := metrics.OutcomeSuccess
+	switch {
+	case err == nil || steps.IsLoopControl(err):
+	case IsLimitExceeded(err):
+		outcome = metrics.OutcomeLimitExceeded
+	default:
+		outcome = metrics.OutcomeFailure
+	}
+	metrics.StepExecutions.Inc(stepType, outcome)
+	metrics.StepDuration.Observe(metrics.Since(start), stepType)
+	return err
+}
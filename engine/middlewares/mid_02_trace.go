@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"fmt"
+
+	playwright "github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/trace"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// traceVar holds the trace node of the current step, which also records the templates evaluated by the step
+const traceVar = utils.TemplateRecorderVar
+
+func init() {
+	registerMiddleware(traceStep)
+}
+
+// BindTrace records the steps executed with the variables under root
+func BindTrace(v utils.Vars, root *trace.Node) {
+	v.SetOnce(traceVar, root)
+}
+
+func traceOf(v utils.Vars) *trace.Node {
+	node, _ := v.GetOr(traceVar, nil).(*trace.Node)
+	return node
+}
+
+// traceStep implements Middleware.
+// When the run is traced, it adds the step to the trace and binds it as the parent of its nested steps.
+// Errors are recorded before on-error handles them
+func traceStep(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next execFunc) error {
+	parent := traceOf(v)
+	if parent == nil {
+		return next(p, s, v, r)
+	}
+	node := parent.StartStep(steps.TypeOf(s.GetConfig()), stepLabel(s.GetConfig()))
+	restore := v.Preserve(traceVar)
+	v.SetOnce(traceVar, node)
+	err := next(p, s, v, r)
+	restore()
+	if steps.IsLoopControl(err) {
+		node.Finish(nil)
+	} else {
+		node.Finish(err)
+	}
+	return err
+}
+
+// traceIteration adds a loop iteration to the trace of the loop, finish must be called once the iteration is done
+func traceIteration(v utils.Vars, index int) (finish func(error)) {
+	parent := traceOf(v)
+	if parent == nil {
+		return func(error) {}
+	}
+	node := parent.StartIteration(index)
+	restore := v.Preserve(traceVar)
+	v.SetOnce(traceVar, node)
+	return func(err error) {
+		restore()
+		node.Finish(err)
+	}
+}
+
+// stepLabel summarizes a step by its type and the value of its key, e.g. `click: #submit`
+func stepLabel(conf config.Step) string {
+	stepType := steps.TypeOf(conf)
+	key := stepType
+	if stepType == "loop" {
+		key, _ = loopKind(conf)
+	}
+	switch value := conf[key].(type) {
+	case string, bool, int, int64, float64:
+		return fmt.Sprintf("%s: %v", key, value)
+	}
+	return stepType
+}
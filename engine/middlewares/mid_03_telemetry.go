@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"context"
+
+	playwright "github.com/mxschmitt/playwright-go"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/telemetry"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// spanVar holds the context of the span of the current step, steps are not exported when it is missing
+const spanVar = utils.HiddenVarPrefix + "span"
+
+func init() {
+	registerMiddleware(spanStep)
+}
+
+// BindSpan exports the steps executed with the variables as children of the span in ctx
+func BindSpan(v utils.Vars, ctx context.Context) {
+	v.SetOnce(spanVar, ctx)
+}
+
+func spanOf(v utils.Vars) context.Context {
+	ctx, _ := v.GetOr(spanVar, nil).(context.Context)
+	return ctx
+}
+
+// spanStep implements Middleware.
+// It wraps the step in an OpenTelemetry span describing its config, with secrets masked
+func spanStep(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next execFunc) error {
+	parent := spanOf(v)
+	if parent == nil {
+		return next(p, s, v, r)
+	}
+	stepType := steps.TypeOf(s.GetConfig())
+	ctx, span := telemetry.Tracer().Start(parent, "step "+stepType)
+	span.SetAttributes(telemetry.StepAttributes(stepType, s.GetConfig(), steps.NestedKeys()...)...)
+	restore := v.Preserve(spanVar)
+	BindSpan(v, ctx)
+	err := next(p, s, v, r)
+	restore()
+	if steps.IsLoopControl(err) {
+		telemetry.End(span, nil)
+	} else {
+		telemetry.End(span, err)
+	}
+	return err
+}
+
+// spanIteration wraps a loop iteration in a span, finish must be called once the iteration is done
+func spanIteration(v utils.Vars, index int) (finish func(error)) {
+	parent := spanOf(v)
+	if parent == nil {
+		return func(error) {}
+	}
+	ctx, span := telemetry.Tracer().Start(parent, "iteration")
+	span.SetAttributes(attribute.Int("scrapper.loop.index", index))
+	restore := v.Preserve(spanVar)
+	BindSpan(v, ctx)
+	return func(err error) {
+		restore()
+		telemetry.End(span, err)
+	}
+}
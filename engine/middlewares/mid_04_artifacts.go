@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"fmt"
+
+	playwright "github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/artifacts"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+const (
+	// artifactsVar holds the recorder of failure artifacts, failures are not captured when it is missing
+	artifactsVar = utils.HiddenVarPrefix + "artifacts"
+	// stepPathVar locates the current step for the artifacts manifest, e.g. `loop: 3 > #1 > click: #buy`
+	stepPathVar = utils.HiddenVarPrefix + "step_path"
+)
+
+func init() {
+	registerMiddleware(captureArtifacts)
+}
+
+// BindArtifacts captures the failures of the steps executed with the variables
+func BindArtifacts(v utils.Vars, rec *artifacts.Recorder) {
+	v.SetOnce(artifactsVar, rec)
+}
+
+func artifactsOf(v utils.Vars) *artifacts.Recorder {
+	rec, _ := v.GetOr(artifactsVar, nil).(*artifacts.Recorder)
+	return rec
+}
+
+// captureArtifacts implements Middleware.
+// When artifacts are enabled, it saves the state of the page once a step fails, before on-error handles the failure
+func captureArtifacts(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next execFunc) error {
+	rec := artifactsOf(v)
+	if rec == nil {
+		return next(p, s, v, r)
+	}
+	path := enterStepPath(v, stepLabel(s.GetConfig()))
+	restore := v.Preserve(stepPathVar)
+	v.SetOnce(stepPathVar, path)
+	err := next(p, s, v, r)
+	restore()
+	if err != nil && !steps.IsLoopControl(err) && !IsLimitExceeded(err) {
+		rec.Capture(p, path, steps.TypeOf(s.GetConfig()), err)
+	}
+	return err
+}
+
+// artifactsIteration adds a loop iteration to the step path, finish must be called once the iteration is done
+func artifactsIteration(v utils.Vars, index int) (finish func()) {
+	if artifactsOf(v) == nil {
+		return func() {}
+	}
+	restore := v.Preserve(stepPathVar)
+	v.SetOnce(stepPathVar, enterStepPath(v, fmt.Sprintf("#%d", index)))
+	return restore
+}
+
+func enterStepPath(v utils.Vars, segment string) string {
+	if parent, _ := v.GetOr(stepPathVar, "").(string); parent != "" {
+		return parent + " > " + segment
+	}
+	return segment
+}
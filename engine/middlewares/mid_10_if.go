@@ -23,14 +23,54 @@ func init() {
 }
 
 // conditionCheck implements Middleware.
+// A step with `then`/`else` is an if block running one of its branches,
+// otherwise the condition guards the step itself.
 func conditionCheck(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next execFunc) error {
 	if s == nil {
 		return errStepMissing
 	}
 
-	exec, err := utils.ChainExec(s.GetConfig(),
+	passed, err := testCondition(p, s, v)
+	if err != nil {
+		switch err {
+		case errNoIf:
+			if next != nil {
+				return next(p, s, v, r)
+			}
+			slog.Warn("no next middleware found, skipping execution")
+			return nil
+		default:
+			return err
+		}
+	}
+
+	if isIfBlock(s.GetConfig()) {
+		return runBranch(p, s, v, r, passed)
+	}
+
+	if passed {
+		if next != nil {
+			return next(p, s, v, r)
+		}
+		slog.Warn("no next middleware found, skipping execution")
+		return nil
+	}
+
+	return errTestFailed
+}
+
+func testCondition(p playwright.Page, s steps.Step, v utils.Vars) (bool, error) {
+	cond, err := getCond(s.GetConfig())
+	if err != nil {
+		return false, err
+	}
+	return evaluateCondition(cond, v, p)
+}
+
+// evaluateCondition renders the condition template and evaluates the resulting query
+func evaluateCondition(cond any, v utils.Vars, p playwright.Page) (bool, error) {
+	exec, err := utils.ChainExec(cond,
 		[]utils.ChainCallback{
-			getCond,
 			func(c any) (any, error) {
 				slog.Debug("evaluating template", slog.Any("variables", v))
 				str, ok := c.(string)
@@ -53,27 +93,33 @@ func conditionCheck(p playwright.Page, s steps.Step, v utils.Vars, r map[string]
 			},
 		})
 	if err != nil {
-		switch err {
-		case errNoIf:
-			if next != nil {
-				return next(p, s, v, r)
-			}
-			slog.Warn("no next middleware found, skipping execution")
-			return nil
-		default:
-			return err
-		}
+		return false, err
 	}
+	return exec.(bool), nil
+}
 
-	if exec.(bool) {
-		if next != nil {
-			return next(p, s, v, r)
-		}
-		slog.Warn("no next middleware found, skipping execution")
+func isIfBlock(conf config.Step) bool {
+	_, hasThen := conf["then"]
+	_, hasElse := conf["else"]
+	return hasThen || hasElse
+}
+
+// runBranch executes `then` when the condition passed and `else` otherwise, a missing branch is a no-op
+func runBranch(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, passed bool) error {
+	branch := "else"
+	if passed {
+		branch = "then"
+	}
+	list, exists, err := nestedSteps(s.GetConfig(), branch)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		slog.Debug("no steps found for branch, skipping", slog.String("branch", branch))
 		return nil
 	}
-
-	return errTestFailed
+	slog.Debug("executing branch", slog.String("branch", branch))
+	return runSteps(p, list, v, r)
 }
 
 func getCond(c any) (any, error) {
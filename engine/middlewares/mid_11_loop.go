@@ -1,127 +1,258 @@
 package middlewares
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 
 	playwright "github.com/mxschmitt/playwright-go"
+	"github.com/spf13/cast"
 	"golang.org/x/exp/slog"
 
 	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/engine/steps"
-	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/policy"
 	"github.com/fmotalleb/scrapper-go/utils"
 )
 
+// loopMetaVar holds the metadata (index, first, last) of the innermost loop
+const loopMetaVar = "loop"
+
+// loopKinds are the keys starting a loop, `loop` iterates over items while
+// `while`/`until` re-check their condition before each iteration
+var loopKinds = []string{"loop", "while", "until"}
+
 func init() {
 	registerMiddleware(forLoop)
 }
 
-// conditionCheck implements Middleware.
+// forLoop implements Middleware.
 func forLoop(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next execFunc) error {
 	if s == nil {
 		return errStepMissing
 	}
 
-	var cond string
-	var ok bool
-	if raw, ok := s.GetConfig()["loop"]; !ok {
+	kind, cond := loopKind(s.GetConfig())
+	if kind == "" {
 		return next(p, s, v, r)
-	} else {
-		if cond, ok = raw.(string); !ok {
-			slog.Error("loop condition found but was unable to read it as an string")
-			return errors.New("")
-		}
 	}
-	var loopKey string
-	if loopKey, ok = s.GetConfig()["loop-key"].(string); !ok {
+	loopKey, ok := s.GetConfig()["loop-key"].(string)
+	if !ok {
 		loopKey = "item"
 	}
-	var nextSteps []steps.Step
+	if err := utils.CheckVarName(loopKey); err != nil {
+		return err
+	}
+	maxIterations, err := readMaxIterations(s.GetConfig())
+	if err != nil {
+		return err
+	}
+	if kind != "loop" && maxIterations == 0 && policy.Current().MaxLoopIterations() == 0 && !limitsOf(v).boundsLoops() {
+		return fmt.Errorf("%s loops require a positive max-iterations", kind)
+	}
+	workers, err := readParallel(s.GetConfig())
+	if err != nil {
+		return err
+	}
 
-	if stepsConfig, exists := s.GetConfig()["steps"]; exists {
-		stepsArray, valid := stepsConfig.([]any)
-		if !valid {
-			slog.Error("expected steps to be an array of maps")
-			return errors.New("steps configuration must be of type []map[string]any")
-		}
-		var innerSteps []config.Step
-		// Iterate over the array and validate each item as a map
-		for i, stepConfig := range stepsArray {
-			stepMap, ok := stepConfig.(map[string]any)
-
-			if !ok {
-				slog.Error("step configuration is not a map", slog.Any("step", stepConfig))
-				return fmt.Errorf("each step must be a map, got: %T at index %d", stepConfig, i)
-			}
-			innerSteps = append(innerSteps, stepMap)
-			slog.Debug("step configuration received", slog.Any("step", stepMap))
-		}
-		var err error
-		nextSteps, err = steps.BuildSteps(innerSteps)
-		if err != nil {
-			slog.Error("failed to build steps from configuration", log.ErrVal(err))
-			return err
-		}
-	} else {
+	nextSteps, exists, err := nestedSteps(s.GetConfig(), "steps")
+	if err != nil {
+		return err
+	}
+	if !exists {
 		slog.Error("steps configuration missing")
 		return errors.New("steps configuration must be provided")
 	}
 
-	slog.Debug("loop condition received", slog.String("condition", cond))
+	// Loop variables only live inside the loop, outer values are restored afterwards
+	restore := v.Preserve(loopKey, loopMetaVar)
+	defer restore()
+
+	slog.Debug("loop condition received", slog.String("kind", kind), slog.Any("condition", cond))
+	if kind != "loop" {
+		return conditionalLoop(p, nextSteps, v, r, cond, kind == "until", maxIterations)
+	}
+
 	items, err := evaluateLoop(cond, v, p)
 	if err != nil {
 		slog.Error("failed to evaluate loop", slog.Any("err", err))
 		return err
 	}
-	for _, i := range items {
+	if maxIterations != 0 && len(items) > maxIterations {
+		return fmt.Errorf("loop has %d items, exceeding max-iterations (%d)", len(items), maxIterations)
+	}
+	if err := policy.Current().CheckLoop(len(items)); err != nil {
+		return err
+	}
+	if err := limitsOf(v).checkLoop(len(items)); err != nil {
+		return err
+	}
+	if workers > 1 && len(items) > 1 {
+		return parallelLoop(p, s.GetConfig(), nextSteps, v, r, items, loopKey, workers)
+	}
+	for index, i := range items {
 		v.SetOnce(loopKey, i)
-		for _, step := range nextSteps {
-			if err := HandleStep(p, step, v, r); err != nil {
-				return err
-			}
+		setLoopMeta(v, index, len(items))
+		finish := startIteration(v, index)
+		stop, err := runIteration(p, nextSteps, v, r)
+		finish(err)
+		if stop {
+			return err
 		}
 	}
 	return nil
 }
 
-func evaluateLoop(cond string, v utils.Vars, p playwright.Page) ([]string, error) {
-	result, err := utils.EvaluateTemplate(cond, v, p)
-	if err != nil {
-		return nil, err
+// conditionalLoop runs the steps while the condition holds (or until it does, when negate is set)
+func conditionalLoop(p playwright.Page, list []steps.Step, v utils.Vars, r map[string]any, cond any, negate bool, maxIterations int) error {
+	for index := 0; ; index++ {
+		passed, err := evaluateCondition(cond, v, p)
+		if err != nil {
+			return err
+		}
+		if passed == negate {
+			return nil
+		}
+		if maxIterations != 0 && index >= maxIterations {
+			return fmt.Errorf("loop exceeded max-iterations (%d)", maxIterations)
+		}
+		if err := policy.Current().CheckLoop(index + 1); err != nil {
+			return err
+		}
+		if err := limitsOf(v).checkLoop(index + 1); err != nil {
+			return err
+		}
+		setLoopMeta(v, index, -1)
+		finish := startIteration(v, index)
+		stop, err := runIteration(p, list, v, r)
+		finish(err)
+		if stop {
+			return err
+		}
 	}
+}
 
-	if num, err := strconv.ParseFloat(result, 64); err == nil {
-		if num != float64(int(num)) { // Not an integer
-			return nil, errors.New("result is a non-integer number")
+// runIteration executes the body of a loop once, stop is set on `break` or on failure
+func runIteration(p playwright.Page, list []steps.Step, v utils.Vars, r map[string]any) (stop bool, err error) {
+	err = runSteps(p, list, v, r)
+	switch {
+	case errors.Is(err, steps.ErrBreak):
+		slog.Debug("loop stopped by break")
+		return true, nil
+	case errors.Is(err, steps.ErrContinue):
+		return false, nil
+	}
+	return err != nil, err
+}
+
+// startIteration records an iteration in the trace, the spans and the step path of the run,
+// finish must be called once it is done
+func startIteration(v utils.Vars, index int) (finish func(error)) {
+	finishTrace := traceIteration(v, index)
+	finishSpan := spanIteration(v, index)
+	finishPath := artifactsIteration(v, index)
+	return func(err error) {
+		finishPath()
+		finishSpan(err)
+		finishTrace(err)
+	}
+}
+
+// setLoopMeta exposes `{{ .loop.index }}`, `{{ .loop.first }}` and `{{ .loop.last }}`,
+// a negative total marks loops with unknown length where last is always false
+func setLoopMeta(v utils.Vars, index int, total int) {
+	v.SetOnce(loopMetaVar, map[string]any{
+		"index": index,
+		"first": index == 0,
+		"last":  total >= 0 && index == total-1,
+	})
+}
+
+func loopKind(conf config.Step) (string, any) {
+	for _, kind := range loopKinds {
+		if cond, ok := conf[kind]; ok {
+			return kind, cond
 		}
-		n := int(num)
-		items := make([]string, n)
-		for i := 0; i < n; i++ {
-			items[i] = strconv.Itoa(i)
+	}
+	return "", nil
+}
+
+func readMaxIterations(conf config.Step) (int, error) {
+	raw, ok := conf["max-iterations"]
+	if !ok {
+		return 0, nil
+	}
+	value, err := cast.ToIntE(raw)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("max-iterations must be a positive integer, got: %v", raw)
+	}
+	return value, nil
+}
+
+// evaluateLoop resolves the items of a loop, the condition can be a number, a list, a map
+// or a template evaluating to one of them (or to their JSON representation)
+func evaluateLoop(cond any, v utils.Vars, p playwright.Page) ([]any, error) {
+	if text, ok := cond.(string); ok {
+		value, err := utils.EvaluateTemplateValue(text, v, p)
+		if err != nil {
+			return nil, err
+		}
+		cond = value
+	}
+
+	switch val := cond.(type) {
+	case []any:
+		return val, nil
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]any, len(keys))
+		for i, k := range keys {
+			items[i] = map[string]any{"key": k, "value": val[k]}
 		}
 		return items, nil
+	case string:
+		return parseLoopString(val)
+	case bool, nil:
+		return nil, fmt.Errorf("unsupported loop value: %v", val)
+	}
+
+	num, err := cast.ToFloat64E(cond)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported loop value of type %T", cond)
+	}
+	return countItems(num)
+}
+
+func parseLoopString(result string) ([]any, error) {
+	if num, err := strconv.ParseFloat(strings.TrimSpace(result), 64); err == nil {
+		return countItems(num)
 	}
 
 	// The result is not a number, parse it as JSON
-	var arr []interface{}
-	if err := json.Unmarshal([]byte(result), &arr); err != nil {
+	parsed, err := utils.ParseJSON(result)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
+	if _, ok := parsed.(string); ok {
+		return nil, errors.New("loop value must be a number, a list or a map")
+	}
+	return evaluateLoop(parsed, nil, nil)
+}
 
-	// Convert []interface{} to []string
-	items := make([]string, len(arr))
-	for i, item := range arr {
-		switch val := item.(type) {
-		case float64:
-			items[i] = strconv.FormatFloat(val, 'f', -1, 64)
-		case string:
-			items[i] = val
-		default:
-			return nil, errors.New("invalid type in JSON array")
-		}
+func countItems(num float64) ([]any, error) {
+	if num != float64(int(num)) { // Not an integer
+		return nil, errors.New("result is a non-integer number")
+	}
+	n := int(num)
+	items := make([]any, n)
+	for i := 0; i < n; i++ {
+		items[i] = i
 	}
 	return items, nil
 }
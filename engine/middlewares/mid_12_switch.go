@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	playwright "github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+func init() {
+	registerMiddleware(switchCase)
+}
+
+// switchCase implements Middleware.
+// It renders the `switch` template and runs the steps of the first case matching it,
+// or the `default` steps when no case matches.
+func switchCase(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next execFunc) error {
+	if s == nil {
+		return errStepMissing
+	}
+
+	raw, ok := s.GetConfig()["switch"]
+	if !ok {
+		return next(p, s, v, r)
+	}
+	text, ok := raw.(string)
+	if !ok {
+		return fmt.Errorf("expected switch to be a string, got: %T", raw)
+	}
+
+	value, err := utils.EvaluateTemplate(text, v, p)
+	if err != nil {
+		slog.Error("failed to evaluate switch template", slog.String("template", text), slog.Any("err", err))
+		return err
+	}
+	slog.Debug("switch value evaluated", slog.String("value", value))
+
+	cases, _ := s.GetConfig()["cases"].([]any)
+	for index, c := range cases {
+		caseConf, ok := c.(map[string]any)
+		if !ok {
+			return fmt.Errorf("each case must be a map, got: %T at index %d", c, index)
+		}
+		if !caseMatches(caseConf["case"], value) {
+			continue
+		}
+		list, exists, err := nestedSteps(caseConf, "steps")
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("case at index %d has no steps", index)
+		}
+		slog.Debug("switch case matched", slog.Int("index", index), slog.Any("case", caseConf["case"]))
+		return runSteps(p, list, v, r)
+	}
+
+	list, exists, err := nestedSteps(s.GetConfig(), "default")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if len(cases) == 0 {
+			return errors.New("switch must have cases or a default block")
+		}
+		slog.Debug("no switch case matched", slog.String("value", value))
+		return nil
+	}
+	return runSteps(p, list, v, r)
+}
+
+// caseMatches compares the value with a single case value or with any item of a list of values
+func caseMatches(caseValue any, value string) bool {
+	if values, ok := caseValue.([]any); ok {
+		for _, item := range values {
+			if utils.ToString(item) == value {
+				return true
+			}
+		}
+		return false
+	}
+	return caseValue != nil && utils.ToString(caseValue) == value
+}
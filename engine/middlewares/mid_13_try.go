@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"errors"
+	"log/slog"
+
+	playwright "github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+func init() {
+	registerMiddleware(tryCatch)
+}
+
+// tryCatch implements Middleware.
+// When a step of `try` fails, `catch` is executed with the error message stored in
+// the `error-key` variable (defaults to `error`), `finally` is executed in any case.
+// break/continue are not errors and pass through catch untouched, neither do exceeded run limits.
+func tryCatch(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next execFunc) error {
+	if s == nil {
+		return errStepMissing
+	}
+
+	trySteps, exists, err := nestedSteps(s.GetConfig(), "try")
+	if !exists {
+		return next(p, s, v, r)
+	}
+	if err != nil {
+		return err
+	}
+	catchSteps, hasCatch, err := nestedSteps(s.GetConfig(), "catch")
+	if err != nil {
+		return err
+	}
+	finallySteps, hasFinally, err := nestedSteps(s.GetConfig(), "finally")
+	if err != nil {
+		return err
+	}
+	if !hasCatch && !hasFinally {
+		return errors.New("try must have a catch or a finally block")
+	}
+	errKey, ok := s.GetConfig()["error-key"].(string)
+	if !ok {
+		errKey = "error"
+	}
+	if err := utils.CheckVarName(errKey); err != nil {
+		return err
+	}
+
+	err = runSteps(p, trySteps, v, r)
+	if err != nil && hasCatch && !steps.IsLoopControl(err) && !IsLimitExceeded(err) {
+		slog.Debug("error caught", slog.String("key", errKey), log.ErrVal(err))
+		restore := v.Preserve(errKey)
+		v.SetOnce(errKey, err.Error())
+		err = runSteps(p, catchSteps, v, r)
+		restore()
+	}
+
+	if hasFinally {
+		if finallyErr := runSteps(p, finallySteps, v, r); finallyErr != nil {
+			if err != nil {
+				slog.Error("finally block failed after a previous error", log.ErrVal(finallyErr))
+				return errors.Join(err, finallyErr)
+			}
+			return finallyErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sync"
+	"time"
+
+	playwright "github.com/mxschmitt/playwright-go"
+	"github.com/spf13/cast"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+const defaultCaptureTimeout = 30 * time.Second
+
+func init() {
+	registerMiddleware(captureResponse)
+}
+
+// captureResponse implements Middleware.
+// Records responses whose URL matches the `capture-response` pattern while the nested `steps`
+// run, the first one (or every one with `all: true`) is stored into `set-var` as
+// `{url, method, status, headers, body}` where a JSON body is parsed.
+func captureResponse(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next execFunc) error {
+	if s == nil {
+		return errStepMissing
+	}
+	conf := s.GetConfig()
+	raw, ok := conf["capture-response"]
+	if !ok {
+		return next(p, s, v, r)
+	}
+	pattern, ok := raw.(string)
+	if !ok || pattern == "" {
+		return fmt.Errorf("capture-response must be a non-empty url pattern, got: %v", raw)
+	}
+	regex, _ := conf["regex"].(bool)
+	all, _ := conf["all"].(bool)
+	timeout, err := readTimeout(conf, defaultCaptureTimeout)
+	if err != nil {
+		return err
+	}
+	nextSteps, hasSteps, err := nestedSteps(conf, "steps")
+	if err != nil {
+		return err
+	}
+	if all && !hasSteps {
+		return errors.New("capture-response with `all: true` requires steps to run while recording")
+	}
+
+	rendered, err := utils.EvaluateTemplate(pattern, v, p)
+	if err != nil {
+		return err
+	}
+	matcher, err := utils.CompileURLPattern(rendered, regex)
+	if err != nil {
+		return fmt.Errorf("invalid capture-response pattern %q: %w", rendered, err)
+	}
+
+	// Listening on the context also records responses of other tabs and popups
+	recorder := newResponseRecorder(matcher, all)
+	handler := recorder.record
+	browserContext := p.Context()
+	browserContext.OnResponse(handler)
+	defer browserContext.RemoveListener("response", handler)
+
+	slog.Debug("capturing responses", slog.String("pattern", rendered), slog.Bool("all", all))
+	if err := runSteps(p, nextSteps, v, r); err != nil {
+		return err
+	}
+	result, err := recorder.wait(timeout)
+	if err != nil {
+		return fmt.Errorf("capture-response %q: %w", rendered, err)
+	}
+	return storeResult(p, s, v, r, result)
+}
+
+// readTimeout accepts a duration string (e.g. `10s`) or a number of milliseconds
+func readTimeout(conf config.Step, fallback time.Duration) (time.Duration, error) {
+	raw, ok := conf["timeout"]
+	if !ok {
+		return fallback, nil
+	}
+	if text, ok := raw.(string); ok {
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timeout %q: %w", text, err)
+		}
+		return duration, nil
+	}
+	ms, err := cast.ToFloat64E(raw)
+	if err != nil || ms <= 0 {
+		return 0, fmt.Errorf("timeout must be a duration or a positive number of milliseconds, got: %v", raw)
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+// responseRecorder collects matching responses, bodies are read outside of the event handler
+// since blocking calls inside Playwright handlers would stall the event loop
+type responseRecorder struct {
+	matcher  *regexp.Regexp
+	all      bool
+	lock     sync.Mutex
+	pending  sync.WaitGroup
+	captured []any
+	stopped  bool
+	first    chan struct{}
+	once     sync.Once
+}
+
+func newResponseRecorder(matcher *regexp.Regexp, all bool) *responseRecorder {
+	return &responseRecorder{
+		matcher: matcher,
+		all:     all,
+		first:   make(chan struct{}),
+	}
+}
+
+func (rr *responseRecorder) record(resp playwright.Response) {
+	if !rr.matcher.MatchString(resp.URL()) {
+		return
+	}
+	rr.lock.Lock()
+	if rr.stopped || (!rr.all && len(rr.captured) > 0) {
+		rr.lock.Unlock()
+		return
+	}
+	index := len(rr.captured)
+	rr.captured = append(rr.captured, nil)
+	rr.pending.Add(1)
+	rr.lock.Unlock()
+
+	go func() {
+		defer rr.pending.Done()
+		entry := responseEntry(resp)
+		rr.lock.Lock()
+		rr.captured[index] = entry
+		rr.lock.Unlock()
+		rr.once.Do(func() { close(rr.first) })
+	}()
+}
+
+// wait returns the first captured response, or the list of every response when recording all of them
+func (rr *responseRecorder) wait(timeout time.Duration) (any, error) {
+	if !rr.all {
+		select {
+		case <-rr.first:
+		case <-time.After(timeout):
+			return nil, fmt.Errorf("no matching response within %s", timeout)
+		}
+		rr.lock.Lock()
+		defer rr.lock.Unlock()
+		return rr.captured[0], nil
+	}
+
+	// Stop recording, so no body read is added while waiting for the pending ones
+	rr.lock.Lock()
+	rr.stopped = true
+	rr.lock.Unlock()
+	done := make(chan struct{})
+	go func() {
+		rr.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("response bodies not received within %s", timeout)
+	}
+	rr.lock.Lock()
+	defer rr.lock.Unlock()
+	return append([]any{}, rr.captured...), nil
+}
+
+func responseEntry(resp playwright.Response) map[string]any {
+	headers := make(map[string]any)
+	for k, val := range resp.Headers() {
+		headers[k] = val
+	}
+	entry := map[string]any{
+		"url":     resp.URL(),
+		"method":  resp.Request().Method(),
+		"status":  resp.Status(),
+		"headers": headers,
+	}
+	body, err := resp.Body()
+	if err != nil {
+		slog.Warn("failed to read captured response body", slog.String("url", resp.URL()), log.ErrVal(err))
+		entry["error"] = err.Error()
+		return entry
+	}
+	if parsed, err := utils.ParseJSON(string(body)); err == nil {
+		entry["body"] = parsed
+	} else {
+		entry["body"] = string(body)
+	}
+	return entry
+}
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -8,6 +9,8 @@ import (
 
 	"github.com/fmotalleb/scrapper-go/engine/steps"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/metrics"
+	"github.com/fmotalleb/scrapper-go/policy"
 	"github.com/fmotalleb/scrapper-go/utils"
 )
 
@@ -21,44 +24,73 @@ func exec(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, next
 		return fmt.Errorf("unexpected middleware after execute middleware: execution should be the final step")
 	}
 
+	// The policy is enforced when running steps as well as when building them
+	for _, kind := range steps.TypesOf(s.GetConfig()) {
+		if err := policy.Current().CheckStep(kind); err != nil {
+			return err
+		}
+	}
 	result, err := s.Execute(p, v, r)
+	if steps.IsLoopControl(err) {
+		return err
+	}
 	if err != nil {
+		if errors.Is(err, playwright.ErrTimeout) {
+			metrics.Timeouts.Inc(metrics.TimeoutStep)
+		}
 		slog.Error("step execution failed", slog.Any("step", s.GetConfig()), log.ErrVal(err))
 		return err
 	}
 
-	if key, ok := s.GetConfig()["set-var"]; ok {
-		strKey, valid := key.(string)
-		if !valid {
-			return fmt.Errorf("expected set-var to be a string, got: %T", key)
-		}
-		if nkey, err := utils.EvaluateTemplate(strKey, v, p); err != nil {
-			slog.Error("failed to evaluate template for set-var key",
-				slog.String("key", strKey),
-				log.ErrVal(err),
-			)
-		} else {
-			strKey = nkey
-		}
-		if err := setOrAppendWithMeta(r, strKey, result); err != nil {
-			slog.Error("failed to store data in variable",
-				slog.String("key", strKey),
-				slog.Any("value", result),
-				slog.Any("table", result),
-				log.ErrVal(err),
-			)
-		} else {
-			slog.Debug("stored result in set-var", slog.String("key", strKey), slog.Any("value", r[strKey]))
-		}
+	return storeResult(p, s, v, r, result)
+}
+
+// storeResult saves the result of a step into the `set-var` key of its config, if there is one
+func storeResult(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any, result any) error {
+	key, ok := s.GetConfig()["set-var"]
+	if !ok {
+		return nil
+	}
+	strKey, valid := key.(string)
+	if !valid {
+		return fmt.Errorf("expected set-var to be a string, got: %T", key)
+	}
+	if nkey, err := utils.EvaluateTemplate(strKey, v, p); err != nil {
+		slog.Error("failed to evaluate template for set-var key",
+			slog.String("key", strKey),
+			log.ErrVal(err),
+		)
+	} else {
+		strKey = nkey
+	}
+	if err := utils.CheckVarName(strKey); err != nil {
+		return err
+	}
+	if err := setOrAppendWithMeta(r, strKey, result, limitsOf(v)); IsLimitExceeded(err) {
+		return err
+	} else if err != nil {
+		slog.Error("failed to store data in variable",
+			slog.String("key", strKey),
+			slog.Any("value", result),
+			slog.Any("table", result),
+			log.ErrVal(err),
+		)
+	} else {
+		// Results share the variable space, so later steps can read them in templates and conditions
+		v.SetOnce(strKey, r[strKey])
+		slog.Debug("stored result in set-var", slog.String("key", strKey), slog.Any("value", r[strKey]))
 	}
 	return nil
 }
 
-func setOrAppendWithMeta(r map[string]any, key string, value any) error {
+func setOrAppendWithMeta(r map[string]any, key string, value any, limits *RunLimits) error {
 	if value == nil {
 		return nil
 	}
-	metaKey := "__$" + key
+	if err := limits.addResult(value); err != nil {
+		return err
+	}
+	metaKey := utils.ResultMetaKey(key)
 	var isFirstTime bool
 	var hasMeta bool
 	if isFirstTime, hasMeta = r[metaKey].(bool); !hasMeta {
@@ -4,10 +4,14 @@ package middlewares
 
 import (
 	"errors"
+	"fmt"
+	"log/slog"
 
 	"github.com/mxschmitt/playwright-go"
 
+	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/log"
 	"github.com/fmotalleb/scrapper-go/utils"
 )
 
@@ -25,6 +29,9 @@ func HandleStep(p playwright.Page, s steps.Step, v utils.Vars, r map[string]any)
 	if len(middlewares) == 0 {
 		return errors.New("no middlewares registered")
 	}
+	if err := limitsOf(v).countStep(); err != nil {
+		return err
+	}
 	return middlewareExec(0, p, s, v, r)
 }
 
@@ -44,3 +51,49 @@ func middlewareExec(index int, p playwright.Page, s steps.Step, v utils.Vars, r
 
 	return current(p, s, v, r, next)
 }
+
+// nestedSteps builds the list of steps found under the key of a block step (e.g. `steps`, `then`),
+// the boolean result reports whether the key is present at all
+func nestedSteps(conf config.Step, key string) ([]steps.Step, bool, error) {
+	stepsConfig, exists := conf[key]
+	if !exists {
+		return nil, false, nil
+	}
+	list, err := buildStepList(key, stepsConfig)
+	return list, true, err
+}
+
+func buildStepList(key string, stepsConfig any) ([]steps.Step, error) {
+	stepsArray, valid := stepsConfig.([]any)
+	if !valid {
+		slog.Error("expected steps to be an array of maps", slog.String("key", key))
+		return nil, fmt.Errorf("%s configuration must be of type []map[string]any", key)
+	}
+	var innerSteps []config.Step
+	// Iterate over the array and validate each item as a map
+	for i, stepConfig := range stepsArray {
+		stepMap, ok := stepConfig.(map[string]any)
+		if !ok {
+			slog.Error("step configuration is not a map", slog.Any("step", stepConfig))
+			return nil, fmt.Errorf("each step must be a map, got: %T at index %d", stepConfig, i)
+		}
+		innerSteps = append(innerSteps, stepMap)
+		slog.Debug("step configuration received", slog.Any("step", stepMap))
+	}
+	result, err := steps.BuildSteps(innerSteps)
+	if err != nil {
+		slog.Error("failed to build steps from configuration", log.ErrVal(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+// runSteps executes the steps in order and stops at the first error
+func runSteps(p playwright.Page, list []steps.Step, v utils.Vars, r map[string]any) error {
+	for _, step := range list {
+		if err := HandleStep(p, step, v, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	playwright "github.com/mxschmitt/playwright-go"
+	"github.com/spf13/cast"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+const (
+	isolationPage    = "page"
+	isolationContext = "context"
+)
+
+type iterationResult struct {
+	executed bool
+	result   map[string]any
+	err      error
+	broke    bool
+	panicked any
+}
+
+// readParallel returns the number of concurrent iterations requested by `parallel`, 1 when missing
+func readParallel(conf config.Step) (int, error) {
+	raw, ok := conf["parallel"]
+	if !ok {
+		return 1, nil
+	}
+	value, err := cast.ToIntE(raw)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("parallel must be a positive integer, got: %v", raw)
+	}
+	return value, nil
+}
+
+// parallelLoop runs the iterations on `workers` pages at once. Every iteration gets its own
+// copy of the variables and its own result map, results are merged in item order once all
+// iterations are done so the output matches a sequential run. Templates of an iteration see
+// the results from before the loop and its own, not the ones of the other iterations.
+// With `on-error: ignore|print` a failing iteration does not stop the others and its results
+// are dropped, otherwise (and on `break`) no new iteration is started and later ones are discarded.
+func parallelLoop(p playwright.Page, conf config.Step, list []steps.Step, v utils.Vars, r map[string]any, items []any, loopKey string, workers int) error {
+	isolation, _ := conf["parallel-isolation"].(string)
+	if isolation == "" {
+		isolation = isolationPage
+	}
+	errMode, _ := conf["on-error"].(string)
+	tolerant := errMode == "ignore" || errMode == "print"
+	workers = min(workers, len(items))
+
+	pages := make([]*steps.Tabs, 0, workers)
+	defer func() {
+		for _, page := range pages {
+			closeWorkerPage(page, isolation)
+		}
+	}()
+	for range workers {
+		page, err := newWorkerPage(p, v, isolation)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, steps.NewTabs(page))
+	}
+
+	slog.Debug("starting parallel loop", slog.Int("workers", workers), slog.Int("items", len(items)), slog.String("isolation", isolation))
+	before := resultsBefore(v, r)
+	results := make([]iterationResult, len(items))
+	jobs := make(chan int)
+	var stopped atomic.Bool
+	var wg sync.WaitGroup
+	for _, page := range pages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				res := runParallelIteration(page, list, v.Clone(), before, loopKey, items[index], index, len(items))
+				results[index] = res
+				if res.broke || res.panicked != nil || (res.err != nil && (!tolerant || IsLimitExceeded(res.err))) {
+					stopped.Store(true)
+				}
+			}
+		}()
+	}
+	for index := range items {
+		if stopped.Load() {
+			break
+		}
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	return mergeIterations(results, r, v, errMode)
+}
+
+func runParallelIteration(p playwright.Page, list []steps.Step, v utils.Vars, before map[string]any, loopKey string, item any, index int, total int) (res iterationResult) {
+	res = iterationResult{executed: true, result: make(map[string]any)}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			res.panicked = recovered
+		}
+	}()
+	bindIterationResults(v, before, res.result)
+	v.SetOnce(loopKey, item)
+	setLoopMeta(v, index, total)
+	BindLimits(v, limitsOf(v).forIteration())
+	finish := startIteration(v, index)
+	defer func() {
+		finish(res.err)
+	}()
+	res.broke, res.err = runIteration(p, list, v, res.result)
+	res.broke = res.broke && res.err == nil
+	return res
+}
+
+// resultsBefore are the public results visible to the loop, read once as the iterations do not change them
+func resultsBefore(v utils.Vars, r map[string]any) map[string]any {
+	if results, ok := v.GetOr(utils.ResultsVar, nil).(map[string]any); ok {
+		return results
+	}
+	return utils.PublicResults(r)
+}
+
+// bindIterationResults exposes the results from before the loop, overridden by the ones of the iteration,
+// as the `results` variable of the iteration
+func bindIterationResults(v utils.Vars, before map[string]any, own map[string]any) {
+	v.SetGetter(utils.ResultsVar, func() any {
+		results := make(map[string]any, len(before)+len(own))
+		maps.Copy(results, before)
+		maps.Copy(results, utils.PublicResults(own))
+		return results
+	})
+}
+
+// mergeIterations appends the results of the iterations in order and reports the first
+// failure (unless on-error tolerates it), iterations after a break or a failure are dropped,
+// like the results of a failed iteration
+func mergeIterations(results []iterationResult, r map[string]any, v utils.Vars, errMode string) error {
+	for index, res := range results {
+		if !res.executed {
+			break
+		}
+		if res.panicked != nil {
+			panic(res.panicked)
+		}
+		if res.err == nil {
+			if err := mergeResult(r, v, res.result); err != nil {
+				return err
+			}
+		}
+		if res.err != nil && !IsLimitExceeded(res.err) {
+			switch errMode {
+			case "ignore":
+				continue
+			case "print":
+				slog.Error("parallel iteration failed, error discarded due to on-error: print", slog.Int("index", index), log.ErrVal(res.err))
+				continue
+			}
+			return fmt.Errorf("iteration %d failed: %w", index, res.err)
+		}
+		if res.broke {
+			break
+		}
+	}
+	return nil
+}
+
+// mergeResult replays the set-var calls of an iteration on the shared result map
+func mergeResult(r map[string]any, v utils.Vars, iteration map[string]any) error {
+	for key, value := range iteration {
+		if utils.IsResultMetaKey(key) {
+			continue
+		}
+		values := []any{value}
+		if single, ok := iteration[utils.ResultMetaKey(key)].(bool); ok && !single {
+			values, _ = value.([]any)
+		}
+		for _, item := range values {
+			if err := setOrAppendWithMeta(r, key, item, limitsOf(v)); err != nil {
+				return err
+			}
+		}
+		v.SetOnce(key, r[key])
+	}
+	return nil
+}
+
+// contextFactoryVar holds the function opening the contexts of isolated parallel loops, see BindContextFactory
+const contextFactoryVar = utils.HiddenVarPrefix + "new_context"
+
+// ContextFactory opens a browser context configured like the first one of the run, starting from state
+type ContextFactory func(state *playwright.StorageState) (playwright.BrowserContext, error)
+
+// BindContextFactory makes `parallel-isolation: context` open its contexts with factory, runs without it
+// use a context with the default options of the browser
+func BindContextFactory(v utils.Vars, factory ContextFactory) {
+	v.SetOnce(contextFactoryVar, factory)
+}
+
+// newWorkerPage opens a page in the same context (sharing cookies and storage) or,
+// for `parallel-isolation: context`, in a new context starting from the current storage state
+// with the routes of the current one
+func newWorkerPage(p playwright.Page, v utils.Vars, isolation string) (playwright.Page, error) {
+	switch isolation {
+	case isolationPage:
+		return p.Context().NewPage()
+	case isolationContext:
+		state, err := p.Context().StorageState()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read storage state: %w", err)
+		}
+		ctx, err := newWorkerContext(p, v, state)
+		if err != nil {
+			return nil, err
+		}
+		if err := steps.CopyRoutes(p.Context(), ctx); err != nil {
+			_ = ctx.Close()
+			return nil, err
+		}
+		// The guard is added last so it runs before the copied routes
+		if err := policy.Guard(ctx); err != nil {
+			_ = ctx.Close()
+			return nil, err
+		}
+		return ctx.NewPage()
+	default:
+		return nil, fmt.Errorf("unknown parallel-isolation %q, expected page or context", isolation)
+	}
+}
+
+func newWorkerContext(p playwright.Page, v utils.Vars, state *playwright.StorageState) (playwright.BrowserContext, error) {
+	if factory, ok := v.GetOr(contextFactoryVar, nil).(ContextFactory); ok {
+		return factory(state)
+	}
+	browser := p.Context().Browser()
+	if browser == nil {
+		return nil, errors.New("current page has no browser to create contexts from")
+	}
+	options := playwright.BrowserNewContextOptions{
+		StorageState: state.ToOptionalStorageState(),
+	}
+	if policy.Current().DownloadsDisabled() {
+		options.AcceptDownloads = playwright.Bool(false)
+	}
+	return browser.NewContext(options)
+}
+
+// closeWorkerPage closes the worker page along with the popups it opened
+func closeWorkerPage(page *steps.Tabs, isolation string) {
+	if isolation == isolationContext {
+		if err := page.Context().Close(); err != nil {
+			slog.Warn("failed to close parallel worker context", log.ErrVal(err))
+		}
+		return
+	}
+	for _, tab := range page.OpenPages() {
+		if err := tab.Close(); err != nil {
+			slog.Warn("failed to close parallel worker page", log.ErrVal(err))
+		}
+	}
+}
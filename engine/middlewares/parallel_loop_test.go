@@ -0,0 +1,222 @@
+package middlewares
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	playwright "github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// fakeContext is a browser context keeping its routes and pages, the other methods are not implemented
+type fakeContext struct {
+	playwright.BrowserContext
+
+	lock   sync.Mutex
+	pages  int
+	routes []any
+	closed bool
+}
+
+func (c *fakeContext) NewPage() (playwright.Page, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pages++
+	return &fakePage{ctx: c}, nil
+}
+
+func (c *fakeContext) StorageState(...playwright.BrowserContextStorageStateOptions) (*playwright.StorageState, error) {
+	return &playwright.StorageState{}, nil
+}
+
+func (c *fakeContext) Route(url any, handler func(playwright.Route), times ...int) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.routes = append(c.routes, url)
+	return nil
+}
+
+func (c *fakeContext) OnClose(func(playwright.BrowserContext)) {}
+
+func (c *fakeContext) Close(...playwright.BrowserContextCloseOptions) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+	return nil
+}
+
+// fakePage only supports what the tabs and the steps of these tests use
+type fakePage struct {
+	playwright.Page
+
+	ctx *fakeContext
+}
+
+func (p *fakePage) Context() playwright.BrowserContext { return p.ctx }
+func (p *fakePage) OnPopup(func(playwright.Page))      {}
+func (p *fakePage) OnClose(func(playwright.Page))      {}
+func (p *fakePage) Close(...playwright.PageCloseOptions) error {
+	return nil
+}
+
+func mustBuild(t *testing.T, step config.Step) steps.Step {
+	t.Helper()
+	built, err := steps.BuildSteps([]config.Step{step})
+	if err != nil {
+		t.Fatalf("BuildSteps failed: %v", err)
+	}
+	return built[0]
+}
+
+// failOn is a step failing for one item of a loop
+func failOn(item int) config.Step {
+	return config.Step{"if": "item == " + utils.ToString(item), "then": nested(config.Step{"omit": utils.HiddenVarPrefix + "fail"})}
+}
+
+func TestParallelLoop(t *testing.T) {
+	items := []any{1, 2, 3, 4, 5, 6}
+	collect := config.Step{"nop": "{{ .item }}", "set-var": "items"}
+	tests := []struct {
+		name    string
+		loop    config.Step
+		want    map[string]any
+		failed  string
+		noItems bool
+	}{
+		{
+			name: "results merged in item order",
+			loop: config.Step{"parallel": 4, "steps": nested(collect)},
+			want: map[string]any{"title": "shop", "items": []any{"1", "2", "3", "4", "5", "6"}},
+		},
+		{
+			name: "iterations see the results before the loop and their own",
+			loop: config.Step{"parallel": 3, "steps": nested(
+				collect,
+				config.Step{"nop": "{{ .results.title }}/{{ .results.items }}", "set-var": "seen"},
+			)},
+			want: map[string]any{
+				"title": "shop",
+				"items": []any{"1", "2", "3", "4", "5", "6"},
+				"seen":  []any{"shop/1", "shop/2", "shop/3", "shop/4", "shop/5", "shop/6"},
+			},
+		},
+		{
+			name: "variables of an iteration are its own",
+			loop: config.Step{"parallel": 6, "steps": nested(
+				config.Step{"nop": "{{ .item }}", "set-var": "last"},
+				config.Step{"nop": "{{ .item }}={{ .last }}", "set-var": "items"},
+			)},
+			want: map[string]any{
+				"title": "shop",
+				"last":  []any{"1", "2", "3", "4", "5", "6"},
+				"items": []any{"1=1", "2=2", "3=3", "4=4", "5=5", "6=6"},
+			},
+		},
+		{
+			name: "failed iterations are dropped with on-error",
+			loop: config.Step{"parallel": 2, "on-error": "ignore", "steps": nested(collect, failOn(2), failOn(5))},
+			want: map[string]any{"title": "shop", "items": []any{"1", "3", "4", "6"}},
+		},
+		{
+			name:   "failures stop the loop",
+			loop:   config.Step{"parallel": 2, "steps": nested(failOn(2), collect)},
+			failed: "iteration 1 failed",
+		},
+		{
+			name: "break drops the later iterations",
+			loop: config.Step{"parallel": 2, "steps": nested(
+				collect,
+				config.Step{"if": "item == 3", "then": nested(config.Step{"break": true})},
+			)},
+			want: map[string]any{"title": "shop", "items": []any{"1", "2", "3"}},
+		},
+		{
+			name:    "unknown isolation",
+			loop:    config.Step{"parallel": 2, "parallel-isolation": "process", "steps": nested(collect)},
+			failed:  "unknown parallel-isolation",
+			noItems: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &fakeContext{}
+			page, _ := ctx.NewPage()
+			v := make(utils.Vars)
+			v.SetOnce("item", "outer")
+			r := map[string]any{"title": "shop", utils.ResultMetaKey("title"): true}
+			tt.loop["loop"] = items
+			err := HandleStep(page, mustBuild(t, tt.loop), v, r)
+			if tt.failed != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.failed) {
+					t.Fatalf("loop = %v, expected %q", err, tt.failed)
+				}
+				if _, ok := r["items"]; ok && tt.noItems {
+					t.Error("results merged by a loop that did not run")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loop failed: %v", err)
+			}
+			if got := utils.PublicResults(r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("results = %v, expected %v", got, tt.want)
+			}
+			if item, _ := v.Get("item"); item != "outer" {
+				t.Errorf("item = %v after the loop, expected the outer value", item)
+			}
+			if items, _ := v.Get("items"); !reflect.DeepEqual(items, tt.want["items"]) {
+				t.Errorf("variable items = %v, expected the merged results", items)
+			}
+		})
+	}
+}
+
+func TestParallelLoopIsolation(t *testing.T) {
+	main := &fakeContext{}
+	page, _ := main.NewPage()
+	var workers []*fakeContext
+	var lock sync.Mutex
+	v := make(utils.Vars)
+	BindContextFactory(v, func(state *playwright.StorageState) (playwright.BrowserContext, error) {
+		if state == nil {
+			t.Error("worker context created without the storage state")
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		ctx := &fakeContext{}
+		workers = append(workers, ctx)
+		return ctx, nil
+	})
+	list := []config.Step{
+		{"route": "**/*.png", "action": "abort"},
+		{"loop": []any{1, 2, 3}, "parallel": 2, "parallel-isolation": "context", "steps": nested(config.Step{"nop": "{{ .item }}", "set-var": "items"})},
+	}
+	r := make(map[string]any)
+	for _, step := range list {
+		if err := HandleStep(page, mustBuild(t, step), v, r); err != nil {
+			t.Fatalf("steps failed: %v", err)
+		}
+	}
+	if len(workers) != 2 {
+		t.Fatalf("%d worker contexts created, expected 2", len(workers))
+	}
+	for _, ctx := range workers {
+		if !reflect.DeepEqual(ctx.routes, []any{"**/*.png"}) {
+			t.Errorf("worker context routes = %v, expected the routes of the run", ctx.routes)
+		}
+		if ctx.pages != 1 || !ctx.closed {
+			t.Errorf("worker context with %d pages, closed: %v, expected one page closed with its context", ctx.pages, ctx.closed)
+		}
+	}
+	if main.closed || main.pages != 1 {
+		t.Error("isolated workers used the context of the run")
+	}
+	if want := []any{"1", "2", "3"}; !reflect.DeepEqual(r["items"], want) {
+		t.Errorf("results = %v, expected %v", r["items"], want)
+	}
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/trace"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// Option customizes a single execution of ExecuteConfig
+type Option func(*options)
+
+type options struct {
+	provider BrowserProvider
+	progress func(done, total int)
+	// limits applied to pipelines that do not set their own, and the maximum they may set
+	defaultLimits config.Limits
+	capLimits     config.Limits
+	trace         *trace.Node
+	// artifactsDir replaces the artifacts directory and the recording paths of pipelines when set and confines
+	// their files to it, an empty value disables them
+	artifactsDir *string
+	// disabledFunctions are template functions disabled for the run, on top of the policy
+	disabledFunctions []string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		provider: launcher{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// BrowserProvider hands out browsers to executions, release is called once the execution no longer needs it.
+// Every execution opens its own browser context, so a browser can be shared between executions
+type BrowserProvider interface {
+	Acquire(ctx context.Context, browserType string, params playwright.BrowserTypeLaunchOptions) (browser playwright.Browser, release func(), err error)
+}
+
+// WithBrowserProvider runs the pipeline on a browser taken from provider instead of launching a new one
+func WithBrowserProvider(provider BrowserProvider) Option {
+	return func(o *options) {
+		o.provider = provider
+	}
+}
+
+// WithProgress reports the number of top-level steps done, it is first called with zero
+// once the browser is ready and then after every step
+func WithProgress(progress func(done, total int)) Option {
+	return func(o *options) {
+		o.progress = progress
+	}
+}
+
+// WithLimits applies defaults to the limits a pipeline leaves unset and caps the ones it sets,
+// zero fields of defaults and caps are ignored
+func WithLimits(defaults, caps config.Limits) Option {
+	return func(o *options) {
+		o.defaultLimits = defaults
+		o.capLimits = caps
+	}
+}
+
+// WithTrace records the steps of the execution under root, see trace.New
+func WithTrace(root *trace.Node) Option {
+	return func(o *options) {
+		o.trace = root
+	}
+}
+
+// WithArtifactsDir stores the failure artifacts and the recordings (`trace.zip` and `videos/`) of pipelines
+// enabling them in dir, instead of the paths they set. The files the pipeline reads and writes (screenshots,
+// storage states, ...) are confined to `files/` in it. An empty dir disables them all, e.g. for executions
+// whose artifacts cannot be retrieved
+func WithArtifactsDir(dir string) Option {
+	return func(o *options) {
+		o.artifactsDir = &dir
+	}
+}
+
+// WithDisabledFunctions disables template functions (e.g. `eval`, or `page` for the `.page` object) for the
+// execution, on top of the ones disabled by the policy
+func WithDisabledFunctions(names ...string) Option {
+	return func(o *options) {
+		o.disabledFunctions = append(o.disabledFunctions, names...)
+	}
+}
+
+// bindFunctions disables the template functions of the options for the steps executed with vars
+func (o *options) bindFunctions(vars utils.Vars) {
+	if len(o.disabledFunctions) > 0 {
+		utils.DisableFunctions(vars, o.disabledFunctions)
+	}
+}
+
+func (o *options) reportProgress(done, total int) {
+	if o.progress != nil {
+		o.progress(done, total)
+	}
+}
+
+// launcher is the default provider, it starts Playwright and a browser for a single execution
+type launcher struct{}
+
+// Acquire implements BrowserProvider.
+func (launcher) Acquire(ctx context.Context, browserType string, params playwright.BrowserTypeLaunchOptions) (playwright.Browser, func(), error) {
+	pw, err := playwright.Run()
+	if err != nil {
+		slog.Error("could not start Playwright", log.ErrVal(err))
+		return nil, nil, fmt.Errorf("playwright startup failed: %w", err)
+	}
+	stop := func() {
+		if err := pw.Stop(); err != nil {
+			slog.Warn("failed to stop Playwright session", log.ErrVal(err))
+		}
+	}
+
+	// Stop Playwright when context is canceled
+	killWithContext(ctx, pw)
+
+	slog.Info("Playwright initialized")
+
+	browser, err := LaunchBrowser(pw, browserType, params)
+	if err != nil {
+		slog.Error("could not launch browser", log.ErrVal(err))
+		stop()
+		return nil, nil, err
+	}
+	release := func() {
+		if err := browser.Close(); err != nil {
+			slog.Error("failed to close browser", log.ErrVal(err))
+		}
+		stop()
+	}
+	return browser, release, nil
+}
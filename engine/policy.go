@@ -0,0 +1,20 @@
+package engine
+
+import (
+	"log/slog"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/policy"
+)
+
+// applyPolicy refuses downloads when the policy disables them, whatever the pipeline asks for
+func applyPolicy(options playwright.BrowserNewPageOptions) playwright.BrowserNewPageOptions {
+	if policy.Current().DownloadsDisabled() {
+		if options.AcceptDownloads != nil && *options.AcceptDownloads {
+			slog.Warn("downloads are disabled by policy, ignoring accept_downloads")
+		}
+		options.AcceptDownloads = playwright.Bool(false)
+	}
+	return options
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+const (
+	recordAlways          = "on"
+	recordRetainOnFailure = "retain-on-failure"
+)
+
+// recordings are the Playwright trace and the videos of a run, written once its browser context is closed
+type recordings struct {
+	trace config.TraceRecording
+	video config.VideoRecording
+
+	context playwright.BrowserContext
+	// lock guards tracing, a canceled run stops it concurrently, and videos, collected as tabs open
+	lock    sync.Mutex
+	tracing bool
+	videos  []playwright.Video
+}
+
+// newRecordings resolves the recordings of a pipeline, the artifacts directory of the options replaces their paths
+func (o *options) newRecordings(pipeline config.Pipeline) (*recordings, error) {
+	rec := &recordings{trace: pipeline.Trace, video: pipeline.Video}
+	for name, mode := range map[string]string{"trace": rec.trace.Mode, "video": rec.video.Mode} {
+		switch mode {
+		case "", recordAlways, recordRetainOnFailure:
+		default:
+			return nil, fmt.Errorf("unknown %s mode %q, expected on or retain-on-failure", name, mode)
+		}
+	}
+	if o.artifactsDir != nil {
+		if rec.trace.Path != "" {
+			rec.trace.Path = filepath.Join(*o.artifactsDir, "trace.zip")
+		}
+		if rec.video.Dir != "" {
+			rec.video.Dir = filepath.Join(*o.artifactsDir, "videos")
+		}
+		if *o.artifactsDir == "" {
+			rec.trace.Path, rec.video.Dir = "", ""
+		}
+	}
+	return rec, nil
+}
+
+// traced reports whether the run records a Playwright trace
+func (rec *recordings) traced() bool {
+	return rec.trace.Path != ""
+}
+
+// applyVideoRecording enables video recording on the page options
+func (rec *recordings) applyVideoRecording(options playwright.BrowserNewPageOptions) playwright.BrowserNewPageOptions {
+	if rec.video.Dir == "" {
+		return options
+	}
+	options.RecordVideo = &playwright.RecordVideo{Dir: playwright.String(rec.video.Dir)}
+	if rec.video.Width > 0 && rec.video.Height > 0 {
+		options.RecordVideo.Size = &playwright.Size{Width: rec.video.Width, Height: rec.video.Height}
+	}
+	slog.Debug("recording videos", slog.String("dir", rec.video.Dir))
+	return options
+}
+
+// start records the trace of the context and collects the videos of its tabs
+func (rec *recordings) start(page playwright.Page) error {
+	rec.context = page.Context()
+	if rec.video.Dir != "" {
+		rec.addVideo(page)
+		rec.context.OnPage(rec.addVideo)
+	}
+	if !rec.traced() {
+		return nil
+	}
+	err := rec.context.Tracing().Start(playwright.TracingStartOptions{
+		Screenshots: playwright.Bool(true),
+		Snapshots:   playwright.Bool(true),
+		Sources:     playwright.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start tracing: %w", err)
+	}
+	rec.tracing = true
+	slog.Debug("recording trace", slog.String("path", rec.trace.Path))
+	return nil
+}
+
+func (rec *recordings) addVideo(page playwright.Page) {
+	if video := page.Video(); video != nil {
+		rec.lock.Lock()
+		defer rec.lock.Unlock()
+		rec.videos = append(rec.videos, video)
+	}
+}
+
+// stop writes the trace, or discards it when it is only retained on failure, it must be called before the context is closed
+func (rec *recordings) stop(failed bool) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	if !rec.tracing {
+		return
+	}
+	rec.tracing = false
+	if rec.trace.Mode == recordRetainOnFailure && !failed {
+		if err := rec.context.Tracing().Stop(); err != nil {
+			slog.Warn("failed to stop tracing", log.ErrVal(err))
+		}
+		rec.trace.Path = ""
+		return
+	}
+	if err := rec.context.Tracing().Stop(rec.trace.Path); err != nil {
+		slog.Error("failed to save trace", slog.String("path", rec.trace.Path), log.ErrVal(err))
+		rec.trace.Path = ""
+	}
+}
+
+// finish deletes the videos only retained on failure and describes the recordings kept, for the output metadata.
+// Videos are complete once the context is closed
+func (rec *recordings) finish(failed bool) map[string]any {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	meta := make(map[string]any)
+	if rec.traced() {
+		meta["trace"] = rec.trace.Path
+	}
+	discard := rec.video.Mode == recordRetainOnFailure && !failed
+	videos := make([]string, 0, len(rec.videos))
+	for _, video := range rec.videos {
+		if discard {
+			if err := video.Delete(); err != nil {
+				slog.Warn("failed to delete video", log.ErrVal(err))
+			}
+			continue
+		}
+		path, err := video.Path()
+		if err != nil {
+			slog.Warn("video was not recorded", log.ErrVal(err))
+			continue
+		}
+		// Report the path under the configured directory, like the trace
+		videos = append(videos, filepath.Join(rec.video.Dir, filepath.Base(path)))
+	}
+	rec.videos = nil
+	if len(videos) > 0 {
+		meta["videos"] = videos
+	}
+	return meta
+}
+
+// withRecordings adds the recordings to the output metadata, out is created when the run failed without output
+func withRecordings(out map[string]any, meta map[string]any) map[string]any {
+	if len(meta) == 0 {
+		return out
+	}
+	if out == nil {
+		out = make(map[string]any)
+	}
+	out[utils.OutputMetaKey] = meta
+	return out
+}
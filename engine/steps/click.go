@@ -13,6 +13,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "click",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["click"].(string)
 			return ok
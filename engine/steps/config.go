@@ -13,6 +13,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "config",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["config"].(map[string]any)
 			return ok
@@ -13,6 +13,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "debug",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["debug"].(string)
 			return ok
@@ -13,6 +13,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "eval",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["eval"].(string)
 			return ok
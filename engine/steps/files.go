@@ -0,0 +1,42 @@
+package steps
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// filesDirVar holds the directory the files of the run are confined to, see BindFilesDir
+const filesDirVar = utils.HiddenVarPrefix + "files_dir"
+
+// ErrFilesDisabled rejects the files of runs that have no directory to keep them, e.g. stateless API requests
+var ErrFilesDisabled = errors.New("files are disabled for this execution")
+
+// BindFilesDir confines the files read and written by the steps (screenshots, storage states, route files)
+// to dir, an empty dir disables them. Runs without it use the paths as they are
+func BindFilesDir(v utils.Vars, dir string) {
+	v.SetOnce(filesDirVar, dir)
+}
+
+// ResolveFile returns the path of a file of the run executed with the variables
+func ResolveFile(v utils.Vars, path string) (string, error) {
+	dir, ok := v.GetOr(filesDirVar, nil).(string)
+	if !ok {
+		return path, nil
+	}
+	return ResolveFileIn(dir, path)
+}
+
+// ResolveFileIn resolves path in dir, paths must be relative and must not escape the directory
+func ResolveFileIn(dir, path string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("%w: %s", ErrFilesDisabled, path)
+	}
+	rel := filepath.Clean(filepath.FromSlash(path))
+	if rel == "." || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid path %q, files must be relative to the directory of the execution", path)
+	}
+	return filepath.Join(dir, rel), nil
+}
@@ -13,6 +13,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "fill",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["fill"].(string)
 			return ok
@@ -15,6 +15,7 @@ type getTextMode string
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "element",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["element"].(string)
 			return ok
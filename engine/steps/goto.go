@@ -8,11 +8,13 @@ import (
 
 	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/policy"
 	"github.com/fmotalleb/scrapper-go/utils"
 )
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "goto",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["goto"].(string)
 			return ok
@@ -47,6 +49,10 @@ func (e *gotoStep) Execute(p playwright.Page, v utils.Vars, r map[string]any) (i
 		return nil, err
 	}
 
+	if err := policy.Current().CheckURL(url); err != nil {
+		return nil, err
+	}
+
 	slog.Debug("navigating to URL", slog.String("url", url))
 	// Navigate to the evaluated URL
 	return p.Goto(url, e.params)
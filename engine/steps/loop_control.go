@@ -0,0 +1,74 @@
+package steps
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/query"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+var (
+	// ErrBreak is returned by the `break` step, loops stop when they receive it
+	ErrBreak = errors.New("break used outside of a loop")
+	// ErrContinue is returned by the `continue` step, loops skip to the next iteration when they receive it
+	ErrContinue = errors.New("continue used outside of a loop")
+)
+
+// IsLoopControl reports whether the error is a break/continue signal rather than a failure
+func IsLoopControl(err error) bool {
+	return errors.Is(err, ErrBreak) || errors.Is(err, ErrContinue)
+}
+
+func init() {
+	for key, signal := range map[string]error{"break": ErrBreak, "continue": ErrContinue} {
+		stepSelectors = append(stepSelectors, stepSelector{
+			Name: key,
+			CanHandle: func(s config.Step) bool {
+				_, ok := s[key]
+				return ok
+			},
+			Generator: func(step config.Step) (Step, error) {
+				cond, _ := step[key].(string)
+				return &loopControl{signal: signal, cond: cond, conf: step}, nil
+			},
+		})
+	}
+}
+
+// loopControl signals its loop, a non-empty string value is a condition (e.g. `break: "{{ .loop.index }} >= 5"`)
+type loopControl struct {
+	signal error
+	cond   string
+	conf   config.Step
+}
+
+func (lc *loopControl) GetConfig() config.Step {
+	return lc.conf
+}
+
+// Execute implements Step.
+func (lc *loopControl) Execute(p playwright.Page, v utils.Vars, r map[string]any) (interface{}, error) {
+	if strings.TrimSpace(lc.cond) == "" {
+		return nil, lc.signal
+	}
+	text, err := utils.EvaluateTemplate(lc.cond, v, p)
+	if err != nil {
+		slog.Error("failed to evaluate loop control condition", slog.String("condition", lc.cond), log.ErrVal(err))
+		return nil, err
+	}
+	q, err := query.ParseQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	passed, err := q.EvaluateQuery(v.Snapshot())
+	if err != nil || !passed {
+		return nil, err
+	}
+	return nil, lc.signal
+}
@@ -13,6 +13,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "mouse",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["mouse"].(string)
 			return ok
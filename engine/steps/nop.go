@@ -11,18 +11,30 @@ import (
 	"github.com/fmotalleb/scrapper-go/utils"
 )
 
+// blockKeys are handled by middlewares (loops, branches, ...), the nop step only gives them a body
+var blockKeys = []string{"loop", "while", "until", "then", "else", "switch", "try", "capture-response"}
+
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "nop",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["nop"].(string)
 			// This enables the branching capabilities like for-loops
-			_, steps := s["loop"].(string)
-			return ok || steps
+			return ok || isBlock(s)
 		},
 		Generator: buildNop,
 	})
 }
 
+func isBlock(s config.Step) bool {
+	for _, key := range blockKeys {
+		if _, ok := s[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 type nop struct {
 	text string
 	conf config.Step
@@ -50,9 +62,11 @@ func buildNop(step config.Step) (Step, error) {
 	// Extract the URL from the step
 	var ok bool
 	if r.text, ok = step["nop"].(string); !ok {
-		if r.text, ok = step["loop"].(string); !ok {
+		if !isBlock(step) {
 			return nil, errors.New("field to build nop node")
 		}
+		// Loops can iterate over native lists and numbers, the text is only kept for templates
+		r.text, _ = step["loop"].(string)
 	}
 
 	return r, nil
@@ -13,6 +13,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "omit",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["omit"].(string)
 			return ok
@@ -38,13 +39,17 @@ func (o *omit) Execute(p playwright.Page, v utils.Vars, r map[string]any) (inter
 		slog.Error("failed to evaluate locator template", slog.Any("variable", o.variable), log.ErrVal(err))
 		return nil, err
 	}
+	if err := utils.CheckVarName(variable); err != nil {
+		return nil, err
+	}
 	delete(v, variable)
 	delete(r, variable)
+	delete(r, utils.ResultMetaKey(variable))
 	return nil, nil
 }
 
 func buildOmit(step config.Step) (Step, error) {
-	r := &omit{}
+	r := &omit{conf: step}
 
 	// Extract the locator for the click action
 	if variable, ok := step["omit"].(string); ok {
@@ -0,0 +1,97 @@
+package steps
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// runOmit builds and executes an omit step, omit does not use the page
+func runOmit(t *testing.T, name string, v utils.Vars, r map[string]any) error {
+	t.Helper()
+	list, err := BuildSteps([]config.Step{{"omit": name}})
+	if err != nil {
+		t.Fatalf("BuildSteps failed: %v", err)
+	}
+	_, err = list[0].Execute(nil, v, r)
+	return err
+}
+
+func TestOmit(t *testing.T) {
+	v := make(utils.Vars)
+	v.SetOnce("title", "shop")
+	r := map[string]any{"title": "shop", utils.ResultMetaKey("title"): true, "other": 1}
+	if err := runOmit(t, "{{ str \"title\" }}", v, r); err != nil {
+		t.Fatalf("omit failed: %v", err)
+	}
+	if _, ok := v.Get("title"); ok {
+		t.Error("omit kept the variable")
+	}
+	if _, ok := r["title"]; ok {
+		t.Error("omit kept the result")
+	}
+	if _, ok := r[utils.ResultMetaKey("title")]; ok {
+		t.Error("omit kept the metadata of the result")
+	}
+	if r["other"] != 1 {
+		t.Error("omit removed another result")
+	}
+}
+
+func TestOmitFilesDir(t *testing.T) {
+	dir := t.TempDir()
+	v := make(utils.Vars)
+	BindFilesDir(v, dir)
+	if err := runOmit(t, filesDirVar, v, map[string]any{}); err == nil {
+		t.Fatalf("omit %q succeeded, expected an error", filesDirVar)
+	}
+	if _, ok := v.Get(filesDirVar); !ok {
+		t.Fatal("omit removed the files directory")
+	}
+	if _, err := ResolveFile(v, "/etc/passwd"); err == nil {
+		t.Error("ResolveFile accepted an absolute path once the files directory was omitted")
+	}
+	if path, err := ResolveFile(v, "state.json"); err != nil || path == "state.json" {
+		t.Errorf("ResolveFile(state.json) = %q, %v, expected a path in %s", path, err, dir)
+	}
+}
+
+func TestResolveFileIn(t *testing.T) {
+	tests := []struct {
+		dir   string
+		path  string
+		valid bool
+	}{
+		{dir: "files", path: "state.json", valid: true},
+		{dir: "files", path: "shots/a.png", valid: true},
+		{dir: "files", path: "shots/../a.png", valid: true},
+		{dir: "files", path: "../a.png", valid: false},
+		{dir: "files", path: "/etc/passwd", valid: false},
+		{dir: "files", path: ".", valid: false},
+		{dir: "files", path: "", valid: false},
+		{dir: "", path: "state.json", valid: false},
+	}
+	for _, tt := range tests {
+		path, err := ResolveFileIn(tt.dir, tt.path)
+		if tt.valid && err != nil {
+			t.Errorf("ResolveFileIn(%q, %q) failed: %v", tt.dir, tt.path, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("ResolveFileIn(%q, %q) = %q, expected an error", tt.dir, tt.path, path)
+		}
+	}
+}
+
+// TestOmitDisabledFunctions checks a run whose api key has no eval cannot enable it again
+func TestOmitDisabledFunctions(t *testing.T) {
+	v := make(utils.Vars)
+	utils.DisableFunctions(v, []string{"eval", "page"})
+	if err := runOmit(t, utils.HiddenVarPrefix+"disabled_functions", v, map[string]any{}); err == nil {
+		t.Fatal("omit of the disabled functions succeeded, expected an error")
+	}
+	if _, err := utils.EvaluateTemplate(`{{ eval "document.cookie" }}`, v, nil); !errors.Is(err, utils.ErrFunctionDisabled) {
+		t.Errorf("eval template = %v, expected %v", err, utils.ErrFunctionDisabled)
+	}
+}
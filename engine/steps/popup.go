@@ -0,0 +1,108 @@
+package steps
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+func init() {
+	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "popup",
+		CanHandle: func(s config.Step) bool {
+			_, ok := s["popup"].(string)
+			return ok
+		},
+		Generator: buildPopup,
+	})
+}
+
+// popup clicks the locator and registers the page it opens as a named tab
+type popup struct {
+	locator  string
+	name     string
+	activate bool
+	params   playwright.LocatorClickOptions
+	conf     config.Step
+}
+
+func (pp *popup) GetConfig() config.Step {
+	return pp.conf
+}
+
+// Execute implements Step.
+func (pp *popup) Execute(p playwright.Page, v utils.Vars, r map[string]any) (interface{}, error) {
+	tabs, err := tabsOf(p)
+	if err != nil {
+		return nil, err
+	}
+	locator, err := utils.EvaluateTemplate(pp.locator, v, p)
+	if err != nil {
+		slog.Error("failed to evaluate locator template", slog.String("locator", pp.locator), log.ErrVal(err))
+		return nil, err
+	}
+	name, err := utils.EvaluateTemplate(pp.name, v, p)
+	if err != nil {
+		slog.Error("failed to evaluate tab name template", slog.String("name", pp.name), log.ErrVal(err))
+		return nil, err
+	}
+
+	page, err := p.ExpectPopup(func() error {
+		return p.Locator(locator).Click(pp.params)
+	})
+	if err != nil {
+		slog.Error("no popup opened by click", slog.String("locator", locator), log.ErrVal(err))
+		return nil, err
+	}
+	if err := page.WaitForLoadState(); err != nil {
+		slog.Warn("popup did not finish loading", slog.String("tab", name), log.ErrVal(err))
+	}
+	if err := tabs.RegisterTab(name, page); err != nil {
+		return nil, err
+	}
+	slog.Debug("popup opened", slog.String("tab", name), slog.String("url", page.URL()))
+	if pp.activate {
+		if err := tabs.SwitchTab(name); err != nil {
+			return nil, err
+		}
+	}
+	return page.URL(), nil
+}
+
+func buildPopup(step config.Step) (Step, error) {
+	r := &popup{
+		conf:     step,
+		activate: true,
+	}
+
+	// Extract the locator that opens the popup
+	if locator, ok := step["popup"].(string); ok {
+		r.locator = locator
+	} else {
+		return nil, fmt.Errorf("expected 'popup' key to be a string, got: %T", step["popup"])
+	}
+	if name, ok := step["name"].(string); ok && name != "" {
+		r.name = name
+	} else {
+		return nil, fmt.Errorf("popup step requires a 'name' for the new tab")
+	}
+	if activate, ok := step["activate"].(bool); ok {
+		r.activate = activate
+	}
+
+	// Load additional parameters
+	r.params = playwright.LocatorClickOptions{}
+	if params, err := utils.LoadParams[playwright.LocatorClickOptions](step); err != nil {
+		slog.Error("failed to load parameters for popup click", log.ErrVal(err), slog.Any("step", step))
+		return nil, err
+	} else {
+		r.params = *params
+	}
+
+	return r, nil
+}
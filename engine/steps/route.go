@@ -0,0 +1,353 @@
+package steps
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mxschmitt/playwright-go"
+	"github.com/spf13/cast"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+func init() {
+	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "route",
+		CanHandle: func(s config.Step) bool {
+			_, ok := s["route"].(string)
+			return ok
+		},
+		Generator: buildRoute,
+	})
+}
+
+type routeAction string
+
+const (
+	routeActionAbort    routeAction = "abort"
+	routeActionContinue routeAction = "continue"
+	routeActionFulfill  routeAction = "fulfill"
+)
+
+type route struct {
+	pattern string
+	regex   bool
+	spec    routeHandling
+	conf    config.Step
+}
+
+// routeHandling describes what happens to intercepted requests, templates in headers, body,
+// file and url are rendered once when the route is registered
+type routeHandling struct {
+	action        routeAction
+	resourceTypes []string
+	headers       map[string]string
+	body          *string
+	file          string
+	status        int
+	contentType   string
+	method        string
+	url           string
+	errorCode     string
+}
+
+func (rt *route) GetConfig() config.Step {
+	return rt.conf
+}
+
+// Execute implements Step.
+func (rt *route) Execute(p playwright.Page, v utils.Vars, r map[string]any) (interface{}, error) {
+	matcher, err := routeMatcher(rt.pattern, rt.regex, v, p)
+	if err != nil {
+		return nil, err
+	}
+	handling, err := rt.evaluate(v, p)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("registering route", slog.String("pattern", rt.pattern), slog.String("action", string(rt.spec.action)))
+	// Routes are registered on the browser context, so they apply to every tab until removed
+	if err := addRoute(p.Context(), matcher, handling.handle); err != nil {
+		slog.Error("failed to register route", slog.String("pattern", rt.pattern), log.ErrVal(err))
+		return nil, err
+	}
+	// Keep the policy checking requests before they reach this route
+	return nil, policy.Guard(p.Context())
+}
+
+// evaluate renders the templates of the step once, when the route is registered
+func (rt *route) evaluate(v utils.Vars, p playwright.Page) (*routeHandling, error) {
+	h := rt.spec
+	var err error
+	if len(rt.spec.headers) > 0 {
+		h.headers = make(map[string]string, len(rt.spec.headers))
+		for k, val := range rt.spec.headers {
+			if h.headers[strings.ToLower(k)], err = utils.EvaluateTemplate(val, v, p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if rt.spec.body != nil {
+		body, err := utils.EvaluateTemplate(*rt.spec.body, v, p)
+		if err != nil {
+			return nil, err
+		}
+		h.body = &body
+	}
+	if h.file, err = utils.EvaluateTemplate(rt.spec.file, v, p); err != nil {
+		return nil, err
+	}
+	if h.file != "" {
+		if h.file, err = ResolveFile(v, h.file); err != nil {
+			return nil, err
+		}
+	}
+	if h.url, err = utils.EvaluateTemplate(rt.spec.url, v, p); err != nil {
+		return nil, err
+	}
+	if h.url != "" {
+		if err := policy.Current().CheckURL(h.url); err != nil {
+			return nil, err
+		}
+	}
+	return &h, nil
+}
+
+func (h *routeHandling) handle(rt playwright.Route) {
+	req := rt.Request()
+	var err error
+	switch {
+	case len(h.resourceTypes) > 0 && !slices.Contains(h.resourceTypes, req.ResourceType()):
+		// Let other routes (or the network) handle requests of other types
+		err = rt.Fallback()
+	case h.action == routeActionAbort:
+		if h.errorCode != "" {
+			err = rt.Abort(h.errorCode)
+		} else {
+			err = rt.Abort()
+		}
+	case h.action == routeActionContinue:
+		err = rt.Continue(h.continueOptions(req))
+	case h.action == routeActionFulfill:
+		err = rt.Fulfill(h.fulfillOptions())
+	}
+	if err != nil {
+		slog.Warn("failed to handle routed request", slog.String("url", req.URL()), slog.String("action", string(h.action)), log.ErrVal(err))
+	}
+}
+
+// continueOptions merges the configured headers into the original ones, an empty value removes a header
+func (h *routeHandling) continueOptions(req playwright.Request) playwright.RouteContinueOptions {
+	opts := playwright.RouteContinueOptions{}
+	if len(h.headers) > 0 {
+		headers := req.Headers()
+		for k, val := range h.headers {
+			if val == "" {
+				delete(headers, k)
+				continue
+			}
+			headers[k] = val
+		}
+		opts.Headers = headers
+	}
+	if h.body != nil {
+		opts.PostData = *h.body
+	}
+	if h.method != "" {
+		opts.Method = playwright.String(h.method)
+	}
+	if h.url != "" {
+		opts.URL = playwright.String(h.url)
+	}
+	return opts
+}
+
+func (h *routeHandling) fulfillOptions() playwright.RouteFulfillOptions {
+	opts := playwright.RouteFulfillOptions{
+		Status:  playwright.Int(h.status),
+		Headers: h.headers,
+	}
+	if h.body != nil {
+		opts.Body = *h.body
+	}
+	if h.file != "" {
+		opts.Path = playwright.String(h.file)
+	}
+	if h.contentType != "" {
+		opts.ContentType = playwright.String(h.contentType)
+	}
+	return opts
+}
+
+// routeMatcher evaluates the pattern and returns either a glob string or a compiled regex
+func routeMatcher(pattern string, regex bool, v utils.Vars, p playwright.Page) (any, error) {
+	evaluated, err := utils.EvaluateTemplate(pattern, v, p)
+	if err != nil {
+		slog.Error("failed to evaluate route pattern template", slog.String("pattern", pattern), log.ErrVal(err))
+		return nil, err
+	}
+	if evaluated == "" {
+		return nil, fmt.Errorf("evaluated route pattern is empty")
+	}
+	if !regex {
+		return evaluated, nil
+	}
+	re, err := regexp.Compile(evaluated)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route regex %q: %w", evaluated, err)
+	}
+	return re, nil
+}
+
+func buildRoute(step config.Step) (Step, error) {
+	r := &route{
+		conf: step,
+		spec: routeHandling{
+			action: routeActionContinue,
+			status: 200,
+		},
+	}
+	spec := &r.spec
+	r.pattern, _ = step["route"].(string)
+	if r.pattern == "" {
+		return nil, fmt.Errorf("route must have a non-empty url pattern, got: %v", step)
+	}
+	r.regex, _ = step["regex"].(bool)
+
+	if act, ok := step["action"]; ok {
+		action, ok := act.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected 'action' to be a string, got: %T", act)
+		}
+		spec.action = routeAction(action)
+	}
+	switch spec.action {
+	case routeActionAbort, routeActionContinue, routeActionFulfill:
+	default:
+		return nil, fmt.Errorf("unknown route action %q, expected abort, continue or fulfill", spec.action)
+	}
+
+	if types, ok := step["resource-types"]; ok {
+		list, err := cast.ToStringSliceE(types)
+		if err != nil {
+			return nil, fmt.Errorf("expected 'resource-types' to be a list of strings, got: %T", types)
+		}
+		spec.resourceTypes = list
+	}
+	if headers, ok := step["headers"]; ok {
+		h, err := cast.ToStringMapStringE(headers)
+		if err != nil {
+			return nil, fmt.Errorf("expected 'headers' to be a map of strings, got: %T", headers)
+		}
+		spec.headers = h
+	}
+	if body, ok := step["body"]; ok {
+		text := utils.ToString(body)
+		spec.body = &text
+	}
+	if status, ok := step["status"]; ok {
+		code, err := cast.ToIntE(status)
+		if err != nil {
+			return nil, fmt.Errorf("expected 'status' to be an integer, got: %v", status)
+		}
+		spec.status = code
+	}
+	spec.file, _ = step["file"].(string)
+	spec.contentType, _ = step["content-type"].(string)
+	spec.method, _ = step["method"].(string)
+	spec.url, _ = step["url"].(string)
+	spec.errorCode, _ = step["error"].(string)
+
+	if spec.body != nil && spec.file != "" {
+		return nil, fmt.Errorf("route step accepts either 'body' or 'file', not both")
+	}
+	if spec.file != "" && spec.action != routeActionFulfill {
+		return nil, fmt.Errorf("'file' is only supported by the fulfill action")
+	}
+	return r, nil
+}
+
+// contextRoutes are the routes registered by route steps on each browser context, so they can be
+// copied to new contexts and removed without touching the other routes (HAR replay, policy guard)
+var contextRoutes = struct {
+	sync.Mutex
+	routes map[playwright.BrowserContext][]registeredRoute
+}{routes: make(map[playwright.BrowserContext][]registeredRoute)}
+
+type registeredRoute struct {
+	matcher any
+	handle  func(playwright.Route)
+}
+
+// addRoute registers handle on the context and tracks it until the context is closed
+func addRoute(ctx playwright.BrowserContext, matcher any, handle func(playwright.Route)) error {
+	// Playwright tells handlers apart by their code, the closure keeps them distinct from the ones of HAR replay
+	entry := registeredRoute{matcher: matcher, handle: func(route playwright.Route) { handle(route) }}
+	if err := ctx.Route(entry.matcher, entry.handle); err != nil {
+		return err
+	}
+	contextRoutes.Lock()
+	defer contextRoutes.Unlock()
+	if _, ok := contextRoutes.routes[ctx]; !ok {
+		ctx.OnClose(forgetRoutes)
+	}
+	contextRoutes.routes[ctx] = append(contextRoutes.routes[ctx], entry)
+	return nil
+}
+
+func forgetRoutes(ctx playwright.BrowserContext) {
+	contextRoutes.Lock()
+	defer contextRoutes.Unlock()
+	delete(contextRoutes.routes, ctx)
+}
+
+// removeRoutes removes the routes added by route steps on the context with the same matcher,
+// all of them when matcher is nil
+func removeRoutes(ctx playwright.BrowserContext, matcher any) error {
+	contextRoutes.Lock()
+	defer contextRoutes.Unlock()
+	var kept []registeredRoute
+	for _, entry := range contextRoutes.routes[ctx] {
+		if matcher != nil && !sameMatcher(entry.matcher, matcher) {
+			kept = append(kept, entry)
+			continue
+		}
+		if err := ctx.Unroute(entry.matcher, entry.handle); err != nil {
+			return err
+		}
+	}
+	if _, ok := contextRoutes.routes[ctx]; ok {
+		contextRoutes.routes[ctx] = kept
+	}
+	return nil
+}
+
+func sameMatcher(a, b any) bool {
+	if re, ok := a.(*regexp.Regexp); ok {
+		other, ok := b.(*regexp.Regexp)
+		return ok && re.String() == other.String()
+	}
+	return a == b
+}
+
+// CopyRoutes registers the routes added by route steps on from to another context, in the same order
+// so they keep their priority. The policy guard is not copied, call policy.Guard once done
+func CopyRoutes(from, to playwright.BrowserContext) error {
+	contextRoutes.Lock()
+	routes := slices.Clone(contextRoutes.routes[from])
+	contextRoutes.Unlock()
+	for _, entry := range routes {
+		if err := addRoute(to, entry.matcher, entry.handle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package steps
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+type fakeRoute struct {
+	matcher any
+	handler func(playwright.Route)
+}
+
+// fakeContext keeps its routes like Playwright does: the last one registered comes first and
+// handlers are told apart by their code
+type fakeContext struct {
+	playwright.BrowserContext
+
+	routes  []fakeRoute
+	onClose []func(playwright.BrowserContext)
+}
+
+func (c *fakeContext) Route(url any, handler func(playwright.Route), times ...int) error {
+	c.routes = append([]fakeRoute{{matcher: url, handler: handler}}, c.routes...)
+	return nil
+}
+
+func (c *fakeContext) Unroute(url any, handlers ...func(playwright.Route)) error {
+	var kept []fakeRoute
+	for _, route := range c.routes {
+		sameHandler := len(handlers) == 0 || reflect.ValueOf(route.handler).Pointer() == reflect.ValueOf(handlers[0]).Pointer()
+		if !sameMatcher(route.matcher, url) || !sameHandler {
+			kept = append(kept, route)
+		}
+	}
+	c.routes = kept
+	return nil
+}
+
+func (c *fakeContext) OnClose(fn func(playwright.BrowserContext)) {
+	c.onClose = append(c.onClose, fn)
+}
+
+func (c *fakeContext) Close(...playwright.BrowserContextCloseOptions) error {
+	for _, fn := range c.onClose {
+		fn(c)
+	}
+	return nil
+}
+
+func (c *fakeContext) matchers() []any {
+	list := make([]any, len(c.routes))
+	for i, route := range c.routes {
+		list[i] = route.matcher
+		if re, ok := route.matcher.(*regexp.Regexp); ok {
+			list[i] = re.String()
+		}
+	}
+	return list
+}
+
+type fakePage struct {
+	playwright.Page
+
+	ctx *fakeContext
+}
+
+func (p *fakePage) Context() playwright.BrowserContext { return p.ctx }
+
+// harRoute stands for the router of replay_har, registered on the context without a route step
+func harRoute(playwright.Route) {}
+
+func runSteps(t *testing.T, p playwright.Page, list ...config.Step) {
+	t.Helper()
+	built, err := BuildSteps(list)
+	if err != nil {
+		t.Fatalf("BuildSteps failed: %v", err)
+	}
+	for _, step := range built {
+		if _, err := step.Execute(p, make(utils.Vars), nil); err != nil {
+			t.Fatalf("%v failed: %v", step.GetConfig(), err)
+		}
+	}
+}
+
+func TestUnroute(t *testing.T) {
+	routes := []config.Step{
+		{"route": "**/*", "action": "continue"},
+		{"route": "**/*.png", "action": "abort"},
+		{"route": `\.js$`, "regex": true, "action": "abort"},
+	}
+	tests := []struct {
+		name    string
+		unroute config.Step
+		want    []any
+	}{
+		{name: "all routes", unroute: config.Step{"unroute": true}, want: []any{"**/*"}},
+		{name: "glob", unroute: config.Step{"unroute": "**/*.png"}, want: []any{`\.js$`, "**/*", "**/*"}},
+		{name: "glob of the har replay", unroute: config.Step{"unroute": "**/*"}, want: []any{`\.js$`, "**/*.png", "**/*"}},
+		{name: "regex", unroute: config.Step{"unroute": `\.js$`, "regex": true}, want: []any{"**/*.png", "**/*", "**/*"}},
+		{name: "glob is not a regex", unroute: config.Step{"unroute": `\.js$`}, want: []any{`\.js$`, "**/*.png", "**/*", "**/*"}},
+		{name: "unknown pattern", unroute: config.Step{"unroute": "**/*.css"}, want: []any{`\.js$`, "**/*.png", "**/*", "**/*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &fakeContext{}
+			defer ctx.Close()
+			_ = ctx.Route("**/*", harRoute)
+			page := &fakePage{ctx: ctx}
+			runSteps(t, page, routes...)
+			runSteps(t, page, tt.unroute)
+			if got := ctx.matchers(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("routes = %v, expected %v", got, tt.want)
+			}
+			last := ctx.routes[len(ctx.routes)-1]
+			if reflect.ValueOf(last.handler).Pointer() != reflect.ValueOf(harRoute).Pointer() {
+				t.Error("unroute removed the har replay")
+			}
+		})
+	}
+}
+
+func TestUnrouteKeepsPolicyGuard(t *testing.T) {
+	p, err := policy.New(policy.Config{URLs: policy.URLRules{Deny: []string{"10.0.0.0/8"}}})
+	if err != nil {
+		t.Fatalf("policy.New failed: %v", err)
+	}
+	policy.Set(p)
+	defer policy.Set(nil)
+
+	ctx := &fakeContext{}
+	defer ctx.Close()
+	page := &fakePage{ctx: ctx}
+	if err := policy.Guard(ctx); err != nil {
+		t.Fatalf("Guard failed: %v", err)
+	}
+	runSteps(t, page, config.Step{"route": "**/*", "action": "abort"}, config.Step{"route": "**/*.png", "action": "abort"})
+	if len(ctx.routes) != 3 {
+		t.Fatalf("%d routes registered, expected 3", len(ctx.routes))
+	}
+	guard := ctx.routes[0]
+	runSteps(t, page, config.Step{"unroute": true})
+	if len(ctx.routes) != 1 || reflect.ValueOf(ctx.routes[0].handler).Pointer() != reflect.ValueOf(guard.handler).Pointer() {
+		t.Errorf("routes = %v after unroute, expected the policy guard only", ctx.matchers())
+	}
+}
+
+func TestCopyRoutes(t *testing.T) {
+	from := &fakeContext{}
+	defer from.Close()
+	_ = from.Route("**/*", harRoute)
+	runSteps(t, &fakePage{ctx: from}, config.Step{"route": "**/*", "action": "continue"}, config.Step{"route": "**/*.png", "action": "abort"})
+
+	to := &fakeContext{}
+	defer to.Close()
+	if err := CopyRoutes(from, to); err != nil {
+		t.Fatalf("CopyRoutes failed: %v", err)
+	}
+	if got, want := to.matchers(), []any{"**/*.png", "**/*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("copied routes = %v, expected %v", got, want)
+	}
+	// The copies belong to the new context, removing them leaves the original ones
+	runSteps(t, &fakePage{ctx: to}, config.Step{"unroute": true})
+	if len(to.routes) != 0 || len(from.routes) != 3 {
+		t.Errorf("routes = %v and %v, expected the copies only to be removed", from.matchers(), to.matchers())
+	}
+
+	from.Close()
+	if err := CopyRoutes(from, to); err != nil || len(to.routes) != 0 {
+		t.Errorf("CopyRoutes of a closed context = %v, %v, expected no routes", err, to.matchers())
+	}
+}
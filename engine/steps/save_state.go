@@ -0,0 +1,75 @@
+package steps
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+func init() {
+	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "save-state",
+		CanHandle: func(s config.Step) bool {
+			_, ok := s["save-state"].(string)
+			return ok
+		},
+		Generator: buildSaveState,
+	})
+}
+
+type saveState struct {
+	path string
+	conf config.Step
+}
+
+func (s *saveState) GetConfig() config.Step {
+	return s.conf
+}
+
+// Execute implements Step.
+func (s *saveState) Execute(p playwright.Page, v utils.Vars, r map[string]any) (interface{}, error) {
+	path, err := utils.EvaluateTemplate(s.path, v, p)
+	if err != nil {
+		slog.Error("failed to evaluate storage state path template", slog.String("path", s.path), log.ErrVal(err))
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("evaluated storage state path is empty")
+	}
+	if path, err = ResolveFile(v, path); err != nil {
+		return nil, err
+	}
+	if err := SaveStorageState(p, path); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+// SaveStorageState writes cookies and localStorage of the page's browser context to path
+func SaveStorageState(p playwright.Page, path string) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create storage state directory: %w", err)
+		}
+	}
+	slog.Debug("saving storage state", slog.String("path", path))
+	if _, err := p.Context().StorageState(playwright.BrowserContextStorageStateOptions{Path: playwright.String(path)}); err != nil {
+		return fmt.Errorf("failed to save storage state: %w", err)
+	}
+	return nil
+}
+
+func buildSaveState(step config.Step) (Step, error) {
+	path, ok := step["save-state"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("save-state must have a non-empty path, got: %v", step)
+	}
+	return &saveState{path: path, conf: step}, nil
+}
@@ -1,11 +1,19 @@
 package steps
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
+	"image"
+	_ "image/jpeg" // decodes the size of jpeg screenshots
+	_ "image/png"  // decodes the size of png screenshots
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/mxschmitt/playwright-go"
+	"github.com/spf13/cast"
 
 	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/log"
@@ -14,6 +22,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "screenshot",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["screenshot"].(string)
 			return ok
@@ -22,10 +31,24 @@ func init() {
 	})
 }
 
+// screenshotPage targets the page (its viewport, or all of it with `full-page`) instead of an element
+const screenshotPage = "page"
+
+const (
+	formatPNG  = "png"
+	formatJPEG = "jpeg"
+)
+
 type screenShot struct {
-	locator string
-	params  playwright.LocatorScreenshotOptions
-	conf    config.Step
+	locator  string
+	fullPage bool
+	format   string
+	quality  *int
+	mask     []string
+	path     string
+	params   playwright.LocatorScreenshotOptions
+	pageOpts playwright.PageScreenshotOptions
+	conf     config.Step
 }
 
 func (sc *screenShot) GetConfig() config.Step {
@@ -33,6 +56,7 @@ func (sc *screenShot) GetConfig() config.Step {
 }
 
 // Execute implements Step.
+// Without a path it returns the image encoded as base64, otherwise it writes the image and returns its path and metadata
 func (sc *screenShot) Execute(p playwright.Page, v utils.Vars, r map[string]any) (interface{}, error) {
 	// Evaluate the locator template
 	locator, err := utils.EvaluateTemplate(sc.locator, v, p)
@@ -48,15 +72,144 @@ func (sc *screenShot) Execute(p playwright.Page, v utils.Vars, r map[string]any)
 		return nil, err
 	}
 
-	// Take a screenshot of the element identified by the locator
-	slog.Debug("taking screenshot for locator", slog.String("locator", locator))
-	data, err := p.Locator(locator).Screenshot(sc.params)
+	mask, err := sc.maskLocators(p, v)
+	if err != nil {
+		return nil, err
+	}
+	var path string
+	if sc.path != "" {
+		if path, err = utils.EvaluateTemplate(sc.path, v, p); err != nil {
+			slog.Error("failed to evaluate screenshot path template", slog.String("path", sc.path), log.ErrVal(err))
+			return nil, err
+		}
+		if path == "" {
+			return nil, fmt.Errorf("evaluated screenshot path is empty")
+		}
+		if path, err = ResolveFile(v, path); err != nil {
+			return nil, err
+		}
+	}
+	format := sc.imageFormat(locator, path)
+	if sc.quality != nil && format != formatJPEG {
+		return nil, fmt.Errorf("'quality' is only supported by jpeg screenshots, got: %s", format)
+	}
+
+	var data []byte
+	if locator == screenshotPage {
+		slog.Debug("taking screenshot of the page", slog.Bool("full_page", sc.fullPage))
+		opts := sc.pageOpts
+		if opts.Path, err = resolveParamsPath(v, opts.Path); err != nil {
+			return nil, err
+		}
+		opts.FullPage = playwright.Bool(sc.fullPage)
+		opts.Type = (*playwright.ScreenshotType)(playwright.String(format))
+		if sc.quality != nil {
+			opts.Quality = sc.quality
+		}
+		if len(mask) > 0 {
+			opts.Mask = mask
+		}
+		data, err = p.Screenshot(opts)
+	} else {
+		slog.Debug("taking screenshot for locator", slog.String("locator", locator))
+		opts := sc.params
+		if opts.Path, err = resolveParamsPath(v, opts.Path); err != nil {
+			return nil, err
+		}
+		opts.Type = (*playwright.ScreenshotType)(playwright.String(format))
+		if sc.quality != nil {
+			opts.Quality = sc.quality
+		}
+		if len(mask) > 0 {
+			opts.Mask = mask
+		}
+		data, err = p.Locator(locator).Screenshot(opts)
+	}
 	if err != nil {
 		slog.Error("failed to take screenshot", log.ErrVal(err))
 		return nil, err
 	}
-	b64 := base64.StdEncoding.EncodeToString(data)
-	return b64, nil
+
+	if path == "" {
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	return writeScreenshot(path, format, data)
+}
+
+// maskLocators evaluates the locators of the elements painted over in the screenshot
+func (sc *screenShot) maskLocators(p playwright.Page, v utils.Vars) ([]playwright.Locator, error) {
+	mask := make([]playwright.Locator, 0, len(sc.mask))
+	for _, tmpl := range sc.mask {
+		selector, err := utils.EvaluateTemplate(tmpl, v, p)
+		if err != nil {
+			slog.Error("failed to evaluate mask template", slog.String("mask", tmpl), log.ErrVal(err))
+			return nil, err
+		}
+		if selector != "" {
+			mask = append(mask, p.Locator(selector))
+		}
+	}
+	return mask, nil
+}
+
+// resolveParamsPath confines the file Playwright writes for `params.path`, like the `path` of the step
+func resolveParamsPath(v utils.Vars, path *string) (*string, error) {
+	if path == nil {
+		return nil, nil
+	}
+	resolved, err := ResolveFile(v, *path)
+	if err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// imageFormat is the `format` of the step, or the one of the path extension, or the `type` of its params.
+// It defaults to jpeg when a quality is set and to png otherwise
+func (sc *screenShot) imageFormat(locator, path string) string {
+	if sc.format != "" {
+		return sc.format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return formatJPEG
+	case ".png":
+		return formatPNG
+	}
+	paramsType := sc.params.Type
+	if locator == screenshotPage {
+		paramsType = sc.pageOpts.Type
+	}
+	if paramsType != nil {
+		return string(*paramsType)
+	}
+	if sc.quality != nil {
+		return formatJPEG
+	}
+	return formatPNG
+}
+
+// writeScreenshot saves the image to path and describes it
+func writeScreenshot(path, format string, data []byte) (map[string]any, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create screenshot directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write screenshot: %w", err)
+	}
+	slog.Debug("screenshot saved", slog.String("path", path), slog.Int("bytes", len(data)))
+	result := map[string]any{
+		"path":   path,
+		"format": format,
+		"bytes":  len(data),
+	}
+	if img, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		result["width"] = img.Width
+		result["height"] = img.Height
+	}
+	return result, nil
 }
 
 func buildScreenShot(step config.Step) (Step, error) {
@@ -70,14 +223,57 @@ func buildScreenShot(step config.Step) (Step, error) {
 		return nil, fmt.Errorf("screenshot must have a string input, got: %v", step)
 	}
 
-	// Load additional parameters for the screenshot
-	r.params = playwright.LocatorScreenshotOptions{}
-	if params, err := utils.LoadParams[playwright.LocatorScreenshotOptions](step); err != nil {
-		slog.Error("failed to read screenshot params", log.ErrVal(err), slog.Any("step", step))
-		return nil, err
+	// Load additional parameters for the screenshot, element and page screenshots take different ones
+	if r.locator == screenshotPage {
+		params, err := utils.LoadParams[playwright.PageScreenshotOptions](step)
+		if err != nil {
+			slog.Error("failed to read screenshot params", log.ErrVal(err), slog.Any("step", step))
+			return nil, err
+		}
+		r.pageOpts = *params
 	} else {
+		params, err := utils.LoadParams[playwright.LocatorScreenshotOptions](step)
+		if err != nil {
+			slog.Error("failed to read screenshot params", log.ErrVal(err), slog.Any("step", step))
+			return nil, err
+		}
 		r.params = *params
 	}
 
+	if fullPage, ok := step["full-page"]; ok {
+		value, err := cast.ToBoolE(fullPage)
+		if err != nil {
+			return nil, fmt.Errorf("expected 'full-page' to be a boolean, got: %v", fullPage)
+		}
+		if value && r.locator != screenshotPage {
+			return nil, fmt.Errorf("'full-page' is only supported by page screenshots (screenshot: %s)", screenshotPage)
+		}
+		r.fullPage = value
+	}
+	r.format, _ = step["format"].(string)
+	if r.format == "jpg" {
+		r.format = formatJPEG
+	}
+	switch r.format {
+	case "", formatPNG, formatJPEG:
+	default:
+		return nil, fmt.Errorf("unknown screenshot format %q, expected png or jpeg", r.format)
+	}
+	if quality, ok := step["quality"]; ok {
+		value, err := cast.ToIntE(quality)
+		if err != nil || value < 0 || value > 100 {
+			return nil, fmt.Errorf("expected 'quality' to be an integer between 0 and 100, got: %v", quality)
+		}
+		r.quality = &value
+	}
+	if mask, ok := step["mask"]; ok {
+		list, err := cast.ToStringSliceE(mask)
+		if err != nil {
+			return nil, fmt.Errorf("expected 'mask' to be a list of locators, got: %T", mask)
+		}
+		r.mask = list
+	}
+	r.path, _ = step["path"].(string)
+
 	return r, nil
 }
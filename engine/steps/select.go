@@ -14,6 +14,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "select",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["select"].(string)
 			return ok
@@ -14,6 +14,7 @@ import (
 
 func init() {
 	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "sleep",
 		CanHandle: func(s config.Step) bool {
 			_, ok := s["sleep"].(string)
 			return ok
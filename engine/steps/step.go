@@ -8,12 +8,18 @@ import (
 	"github.com/mxschmitt/playwright-go"
 
 	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
 	"github.com/fmotalleb/scrapper-go/utils"
 )
 
 func BuildSteps(steps []config.Step) ([]Step, error) {
 	output := make([]Step, len(steps))
-	var err error
+	// Nested steps are checked too, so a pipeline breaking the policy fails before running anything
+	err := Walk(steps, CheckPolicy)
+	if err != nil {
+		slog.Warn("steps rejected by policy", log.ErrVal(err))
+		return nil, err
+	}
 
 	for index, step := range steps {
 		var handled bool
@@ -42,6 +48,8 @@ func BuildSteps(steps []config.Step) ([]Step, error) {
 var stepSelectors []stepSelector
 
 type stepSelector struct {
+	// Name is the type of the steps it handles, usually the key selecting them
+	Name      string
 	CanHandle func(config.Step) bool
 	Generator stepGenerator
 }
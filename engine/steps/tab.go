@@ -0,0 +1,151 @@
+package steps
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+func init() {
+	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "tab",
+		CanHandle: func(s config.Step) bool {
+			_, ok := s["tab"].(string)
+			return ok
+		},
+		Generator: buildTab,
+	})
+}
+
+type tabAction string
+
+const (
+	tabActionOpen   tabAction = "open"
+	tabActionSwitch tabAction = "switch"
+	tabActionClose  tabAction = "close"
+	tabActionList   tabAction = "list"
+)
+
+type tab struct {
+	name     string
+	action   tabAction
+	url      string
+	activate bool
+	conf     config.Step
+}
+
+func (t *tab) GetConfig() config.Step {
+	return t.conf
+}
+
+// Execute implements Step.
+func (t *tab) Execute(p playwright.Page, v utils.Vars, r map[string]any) (interface{}, error) {
+	tabs, err := tabsOf(p)
+	if err != nil {
+		return nil, err
+	}
+	if t.action == tabActionList {
+		return tabList(tabs), nil
+	}
+
+	name, err := utils.EvaluateTemplate(t.name, v, p)
+	if err != nil {
+		slog.Error("failed to evaluate tab name template", slog.String("name", t.name), log.ErrVal(err))
+		return nil, err
+	}
+	if name == "" {
+		return nil, fmt.Errorf("evaluated tab name is empty")
+	}
+
+	slog.Debug("tab action", slog.String("tab", name), slog.String("action", string(t.action)))
+	switch t.action {
+	case tabActionSwitch:
+		return nil, tabs.SwitchTab(name)
+	case tabActionClose:
+		return nil, tabs.CloseTab(name)
+	case tabActionOpen:
+		return nil, t.open(tabs, name, v)
+	}
+	return nil, fmt.Errorf("unknown tab action: %s", t.action)
+}
+
+func (t *tab) open(tabs *Tabs, name string, v utils.Vars) error {
+	url, err := utils.EvaluateTemplate(t.url, v, tabs)
+	if err != nil {
+		return err
+	}
+	if url != "" {
+		if err := policy.Current().CheckURL(url); err != nil {
+			return err
+		}
+	}
+	page, err := tabs.Context().NewPage()
+	if err != nil {
+		slog.Error("failed to open a new tab", log.ErrVal(err))
+		return err
+	}
+	if err := tabs.RegisterTab(name, page); err != nil {
+		_ = page.Close()
+		return err
+	}
+	if url != "" {
+		if _, err := page.Goto(url); err != nil {
+			slog.Error("failed to navigate new tab", slog.String("url", url), log.ErrVal(err))
+			return err
+		}
+	}
+	if t.activate {
+		return tabs.SwitchTab(name)
+	}
+	return nil
+}
+
+// tabList converts the open tabs to plain values, so they can be used in templates and output
+func tabList(tabs *Tabs) []any {
+	infos := tabs.ListTabs()
+	result := make([]any, len(infos))
+	for i, info := range infos {
+		result[i] = map[string]any{
+			"name":   info.Name,
+			"url":    info.URL,
+			"active": info.Active,
+		}
+	}
+	return result
+}
+
+func buildTab(step config.Step) (Step, error) {
+	r := &tab{
+		conf:     step,
+		activate: true,
+	}
+	r.name, _ = step["tab"].(string)
+
+	r.action = tabActionSwitch
+	if act, ok := step["action"]; ok {
+		action, ok := act.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected 'action' to be a string, got: %T", act)
+		}
+		r.action = tabAction(action)
+	}
+	switch r.action {
+	case tabActionOpen, tabActionSwitch, tabActionClose, tabActionList:
+	default:
+		return nil, fmt.Errorf("unknown tab action %q, expected open, switch, close or list", r.action)
+	}
+
+	if url, ok := step["url"].(string); ok {
+		r.url = url
+	}
+	if activate, ok := step["activate"].(bool); ok {
+		r.activate = activate
+	}
+	return r, nil
+}
@@ -0,0 +1,202 @@
+package steps
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/mxschmitt/playwright-go"
+)
+
+// MainTab is the name of the page a pipeline starts with
+const MainTab = "main"
+
+// Tabs keeps the named pages of a pipeline, it behaves as the active page so every step
+// keeps working on whichever tab was switched to last.
+// Popups (including `target=_blank` links) opened by a tab are registered as `tab-N`.
+type Tabs struct {
+	playwright.Page
+
+	lock    sync.Mutex
+	pages   map[string]playwright.Page
+	order   []string
+	active  string
+	counter int
+}
+
+// TabInfo describes an open tab
+type TabInfo struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Active bool   `json:"active"`
+}
+
+func NewTabs(page playwright.Page) *Tabs {
+	t := &Tabs{
+		Page:  page,
+		pages: make(map[string]playwright.Page),
+	}
+	t.track(MainTab, page)
+	t.active = MainTab
+	return t
+}
+
+// ActiveTab returns the name of the current page
+func (t *Tabs) ActiveTab() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.active
+}
+
+// RegisterTab names a page (renaming it when it is already known) without switching to it
+func (t *Tabs) RegisterTab(name string, page playwright.Page) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if existing, ok := t.pages[name]; ok && existing != page {
+		return fmt.Errorf("tab %q already exists", name)
+	}
+	switch old := t.nameOf(page); old {
+	case name:
+	case "":
+		t.track(name, page)
+	default:
+		t.rename(old, name)
+	}
+	return nil
+}
+
+// SwitchTab makes the named tab the active page
+func (t *Tabs) SwitchTab(name string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	page, ok := t.pages[name]
+	if !ok {
+		return fmt.Errorf("no tab named %q", name)
+	}
+	t.Page = page
+	t.active = name
+	if err := page.BringToFront(); err != nil {
+		slog.Warn("failed to bring tab to front", slog.String("tab", name), slog.Any("error", err))
+	}
+	return nil
+}
+
+// CloseTab closes the named tab, closing the active tab switches back to the previous one
+func (t *Tabs) CloseTab(name string) error {
+	t.lock.Lock()
+	page, ok := t.pages[name]
+	if !ok {
+		t.lock.Unlock()
+		return fmt.Errorf("no tab named %q", name)
+	}
+	if len(t.pages) == 1 {
+		t.lock.Unlock()
+		return fmt.Errorf("cannot close %q, it is the last open tab", name)
+	}
+	t.remove(name)
+	fallback := t.order[len(t.order)-1]
+	wasActive := t.active == name
+	t.lock.Unlock()
+
+	if wasActive {
+		if err := t.SwitchTab(fallback); err != nil {
+			return err
+		}
+	}
+	return page.Close()
+}
+
+// ListTabs returns the open tabs in the order they were opened
+func (t *Tabs) ListTabs() []TabInfo {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	result := make([]TabInfo, len(t.order))
+	for i, name := range t.order {
+		result[i] = TabInfo{
+			Name:   name,
+			URL:    t.pages[name].URL(),
+			Active: name == t.active,
+		}
+	}
+	return result
+}
+
+// OpenPages returns every open page of the tabs
+func (t *Tabs) OpenPages() []playwright.Page {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	result := make([]playwright.Page, 0, len(t.pages))
+	for _, name := range t.order {
+		result = append(result, t.pages[name])
+	}
+	return result
+}
+
+// track must be called with the lock held
+func (t *Tabs) track(name string, page playwright.Page) {
+	t.pages[name] = page
+	t.order = append(t.order, name)
+	page.OnPopup(t.adopt)
+	page.OnClose(t.forget)
+}
+
+func (t *Tabs) adopt(page playwright.Page) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.nameOf(page) != "" {
+		return
+	}
+	t.counter++
+	name := fmt.Sprintf("tab-%d", t.counter)
+	t.track(name, page)
+	slog.Debug("popup registered as a new tab", slog.String("tab", name))
+}
+
+// forget drops closed pages, the active tab is kept until a step switches away from it
+func (t *Tabs) forget(page playwright.Page) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if name := t.nameOf(page); name != "" && name != t.active {
+		t.remove(name)
+	}
+}
+
+func (t *Tabs) nameOf(page playwright.Page) string {
+	for name, p := range t.pages {
+		if p == page {
+			return name
+		}
+	}
+	return ""
+}
+
+func (t *Tabs) rename(old, name string) {
+	t.pages[name] = t.pages[old]
+	delete(t.pages, old)
+	for i, n := range t.order {
+		if n == old {
+			t.order[i] = name
+		}
+	}
+	if t.active == old {
+		t.active = name
+	}
+}
+
+func (t *Tabs) remove(name string) {
+	delete(t.pages, name)
+	for i, n := range t.order {
+		if n == name {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func tabsOf(p playwright.Page) (*Tabs, error) {
+	tabs, ok := p.(*Tabs)
+	if !ok {
+		return nil, fmt.Errorf("tabs are not available for this page")
+	}
+	return tabs, nil
+}
@@ -0,0 +1,136 @@
+package steps
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/spf13/cast"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/policy"
+)
+
+// blockTypes names the blocks by the keys that make them, loop variants are all `loop`
+var blockTypes = map[string]string{
+	"loop":             "loop",
+	"while":            "loop",
+	"until":            "loop",
+	"then":             "if",
+	"else":             "if",
+	"switch":           "switch",
+	"try":              "try",
+	"capture-response": "capture-response",
+}
+
+// blockOrder is the order in which the middlewares run the blocks, outermost first
+var blockOrder = []string{"if", "loop", "switch", "try", "capture-response"}
+
+// nestedKeys hold the steps of blocks, they must follow the keys read by the middlewares
+var nestedKeys = []string{"steps", "then", "else", "try", "catch", "finally", "default"}
+
+// TypesOf returns the types of a step: the block it makes (e.g. `loop`) and the step it runs (e.g. `goto`),
+// nested steps are not included (see Walk)
+func TypesOf(step config.Step) []string {
+	var types []string
+	seen := make(map[string]bool)
+	for key, name := range blockTypes {
+		if _, ok := step[key]; ok && !seen[name] {
+			seen[name] = true
+			types = append(types, name)
+		}
+	}
+	for _, selector := range stepSelectors {
+		if selector.CanHandle(step) {
+			if selector.Name != "nop" || !isBlock(step) {
+				types = append(types, selector.Name)
+			}
+			break
+		}
+	}
+	return types
+}
+
+// TypeOf returns the main type of a step: its outermost block, or the step it runs
+func TypeOf(step config.Step) string {
+	types := TypesOf(step)
+	for _, block := range blockOrder {
+		if slices.Contains(types, block) {
+			return block
+		}
+	}
+	if len(types) == 0 {
+		return "unknown"
+	}
+	return types[len(types)-1]
+}
+
+// NestedKeys returns the keys holding nested steps, `cases` included
+func NestedKeys() []string {
+	return append(slices.Clone(nestedKeys), "cases")
+}
+
+// Walk calls fn on every step of the list, then on the steps nested in its blocks, depth first
+func Walk(list []config.Step, fn func(config.Step) error) error {
+	for _, step := range list {
+		if err := fn(step); err != nil {
+			return err
+		}
+		for _, key := range nestedKeys {
+			if err := Walk(stepList(step[key]), fn); err != nil {
+				return err
+			}
+		}
+		cases, _ := step["cases"].([]any)
+		for _, c := range cases {
+			if caseConf, ok := c.(map[string]any); ok {
+				if err := Walk(stepList(caseConf["steps"]), fn); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// stepList keeps the steps of a nested list, malformed entries are left to the middlewares to report
+func stepList(value any) []config.Step {
+	items, _ := value.([]any)
+	result := make([]config.Step, 0, len(items))
+	for _, item := range items {
+		switch step := item.(type) {
+		case map[string]any:
+			result = append(result, step)
+		case config.Step:
+			result = append(result, step)
+		}
+	}
+	return result
+}
+
+// CheckPolicy rejects a step breaking the current policy, using what is known before its execution:
+// its kinds, literal urls and literal loop sizes. Templated values are checked when they are executed
+func CheckPolicy(step config.Step) error {
+	p := policy.Current()
+	if p == nil {
+		return nil
+	}
+	for _, kind := range TypesOf(step) {
+		if err := p.CheckStep(kind); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"goto", "url"} {
+		if url, ok := step[key].(string); ok && !strings.Contains(url, "{{") {
+			if err := p.CheckURL(url); err != nil {
+				return err
+			}
+		}
+	}
+	switch items := step["loop"].(type) {
+	case []any:
+		return p.CheckLoop(len(items))
+	case int, int64, float64:
+		return p.CheckLoop(cast.ToInt(items))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package steps
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+func init() {
+	stepSelectors = append(stepSelectors, stepSelector{
+		Name: "unroute",
+		CanHandle: func(s config.Step) bool {
+			switch s["unroute"].(type) {
+			case string, bool:
+				return true
+			}
+			return false
+		},
+		Generator: buildUnroute,
+	})
+}
+
+type unroute struct {
+	pattern string
+	regex   bool
+	conf    config.Step
+}
+
+func (u *unroute) GetConfig() config.Step {
+	return u.conf
+}
+
+// Execute implements Step.
+// Only the routes of route steps are removed, HAR replay and the policy guard keep handling requests
+func (u *unroute) Execute(p playwright.Page, v utils.Vars, r map[string]any) (interface{}, error) {
+	if u.pattern == "" {
+		slog.Debug("removing all routes")
+		return nil, removeRoutes(p.Context(), nil)
+	}
+	matcher, err := routeMatcher(u.pattern, u.regex, v, p)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("removing route", slog.String("pattern", u.pattern))
+	if err := removeRoutes(p.Context(), matcher); err != nil {
+		slog.Error("failed to remove route", slog.String("pattern", u.pattern), log.ErrVal(err))
+		return nil, err
+	}
+	return nil, nil
+}
+
+func buildUnroute(step config.Step) (Step, error) {
+	r := &unroute{conf: step}
+	switch val := step["unroute"].(type) {
+	case string:
+		if val == "" {
+			return nil, fmt.Errorf("unroute must have a non-empty url pattern, use `unroute: true` to remove all routes")
+		}
+		r.pattern = val
+	case bool:
+		if !val {
+			return nil, fmt.Errorf("unroute must be a url pattern or true, got: false")
+		}
+	}
+	r.regex, _ = step["regex"].(bool)
+	return r, nil
+}
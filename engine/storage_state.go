@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/log"
+)
+
+// applyStorageState loads the saved storage state into the page options if the file exists,
+// a missing file is not an error so the first run can create it
+func applyStorageState(options playwright.BrowserNewPageOptions, state config.StorageState) playwright.BrowserNewPageOptions {
+	if state.Path == "" || options.StorageStatePath != nil || options.StorageState != nil {
+		return options
+	}
+	if _, err := os.Stat(state.Path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.Info("storage state file not found, starting with a clean context", slog.String("path", state.Path))
+		} else {
+			slog.Warn("failed to access storage state file", slog.String("path", state.Path), log.ErrVal(err))
+		}
+		return options
+	}
+	slog.Debug("loading storage state", slog.String("path", state.Path))
+	options.StorageStatePath = playwright.String(state.Path)
+	return options
+}
+
+// autoSaveStorageState writes the storage state back to its file when auto_save is enabled
+func autoSaveStorageState(page playwright.Page, state config.StorageState) {
+	if !state.AutoSave || state.Path == "" {
+		return
+	}
+	if err := steps.SaveStorageState(page, state.Path); err != nil {
+		slog.Warn("failed to save storage state", slog.String("path", state.Path), log.ErrVal(err))
+	}
+}
@@ -0,0 +1,28 @@
+package engine
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/middlewares"
+	"github.com/fmotalleb/scrapper-go/telemetry"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// startSpan starts the root span of a run or of a session request, steps is the number of top-level steps
+func startSpan(ctx context.Context, name string, pipeline config.Pipeline, steps int) (context.Context, oteltrace.Span) {
+	return telemetry.Tracer().Start(ctx, name, oteltrace.WithAttributes(
+		attribute.String("scrapper.browser", pipeline.Browser),
+		attribute.Int("scrapper.steps", steps),
+	))
+}
+
+// bindSpan makes the steps executed with vars children of the span in ctx, when spans are exported
+func bindSpan(ctx context.Context, vars utils.Vars) {
+	if telemetry.Enabled() {
+		middlewares.BindSpan(vars, ctx)
+	}
+}
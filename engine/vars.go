@@ -16,6 +16,9 @@ func initializeVariables(varsConfig []config.Variable) (utils.Vars, error) {
 			slog.Warn("skipping variable with empty name", slog.Any("variable", v))
 			continue
 		}
+		if err := utils.CheckVarName(v.Name); err != nil {
+			return nil, err
+		}
 
 		var value string
 		switch v.Random {
@@ -25,16 +28,16 @@ func initializeVariables(varsConfig []config.Variable) (utils.Vars, error) {
 			slog.Debug("initialized 'once' random variable", slog.String("name", v.Name), slog.String("value", value))
 
 		case "always":
-			vars.SetGetter(v.Name, func() string {
+			vars.SetGetter(v.Name, func() any {
 				return v.Prefix + utils.RandomString(v.RandomChars, v.RandomLength) + v.Postfix
 			})
 			slog.Debug("initialized 'always' random variable getter", slog.String("name", v.Name))
 
 		default:
-			if v.Value != "" {
-				value = v.Prefix + v.Value + v.Postfix
-				vars.SetOnce(v.Name, value)
-				slog.Debug("initialized fixed variable", slog.String("name", v.Name), slog.String("value", value))
+			if v.Value != nil {
+				fixed := fixedValue(v)
+				vars.SetOnce(v.Name, fixed)
+				slog.Debug("initialized fixed variable", slog.String("name", v.Name), slog.Any("value", fixed))
 			} else {
 				slog.Error("unknown variable configuration", slog.Any("variable", v))
 				return nil, fmt.Errorf("invalid variable configuration: %v", v)
@@ -45,3 +48,22 @@ func initializeVariables(varsConfig []config.Variable) (utils.Vars, error) {
 	slog.Info("variables initialization completed", slog.Int("total", len(varsConfig)))
 	return vars, nil
 }
+
+// fixedValue keeps the native type of numbers, booleans, lists and maps,
+// prefix and postfix turn the value into a string
+func fixedValue(v config.Variable) any {
+	if str, ok := v.Value.(string); ok {
+		return v.Prefix + str + v.Postfix
+	}
+	if v.Prefix != "" || v.Postfix != "" {
+		return v.Prefix + utils.ToString(v.Value) + v.Postfix
+	}
+	return v.Value
+}
+
+// bindResults exposes the public part of the result map as the `results` variable
+func bindResults(vars utils.Vars, result map[string]any) {
+	vars.SetGetter(utils.ResultsVar, func() any {
+		return utils.PublicResults(result)
+	})
+}
@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+func TestInitializeVariablesReservedNames(t *testing.T) {
+	for _, name := range []string{utils.HiddenVarPrefix + "disabled_functions", utils.HiddenVarPrefix + "files_dir", utils.HiddenVarPrefix + "limits"} {
+		if _, err := initializeVariables([]config.Variable{{Name: name, Value: []any{}}}); err == nil {
+			t.Errorf("initializeVariables accepted the reserved name %q", name)
+		}
+	}
+	vars, err := initializeVariables([]config.Variable{{Name: "title", Value: "shop"}})
+	if err != nil {
+		t.Fatalf("initializeVariables failed: %v", err)
+	}
+	if value, _ := vars.Get("title"); value != "shop" {
+		t.Errorf("title = %v, expected shop", value)
+	}
+}
+
+// TestDisabledFunctionsOption checks the functions disabled for an api key are bound to the run
+func TestDisabledFunctionsOption(t *testing.T) {
+	vars := make(utils.Vars)
+	newOptions([]Option{WithDisabledFunctions("eval", "page")}).bindFunctions(vars)
+	if _, err := utils.EvaluateTemplate(`{{ eval "1" }}`, vars, nil); err == nil {
+		t.Error("eval template succeeded, expected it to be disabled")
+	}
+	vars = make(utils.Vars)
+	newOptions(nil).bindFunctions(vars)
+	if _, ok := vars.Get(utils.HiddenVarPrefix + "disabled_functions"); ok {
+		t.Error("functions disabled without the option")
+	}
+}
@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fmotalleb/scrapper-go/log"
+)
+
+// FileStore keeps every job as a JSON file in a directory, so jobs survive restarts
+type FileStore struct {
+	lock sync.RWMutex
+	dir  string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create jobs directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save implements Store.
+func (s *FileStore) Save(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// Write to a temporary file first, so a crash never leaves a truncated job behind
+	tmp := s.path(job.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	return os.Rename(tmp, s.path(job.ID))
+}
+
+// Get implements Store.
+func (s *FileStore) Get(id string) (Job, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.read(s.path(id))
+}
+
+// List implements Store.
+func (s *FileStore) List(filter Filter) ([]Job, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Job, 0, len(files))
+	for _, file := range files {
+		job, err := s.read(file)
+		if err != nil {
+			slog.Warn("skipping unreadable job file", slog.String("file", file), log.ErrVal(err))
+			continue
+		}
+		if filter.Match(job) {
+			result = append(result, job)
+		}
+	}
+	return limitJobs(result, filter.Limit), nil
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) path(id string) string {
+	// IDs come from the url, keep them inside the store directory
+	return filepath.Join(s.dir, strings.ReplaceAll(filepath.Base(id), ".", "_")+".json")
+}
+
+func (s *FileStore) read(path string) (Job, error) {
+	var job Job
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return job, ErrNotFound
+	}
+	if err != nil {
+		return job, err
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		return job, fmt.Errorf("failed to decode job: %w", err)
+	}
+	return job, nil
+}
@@ -0,0 +1,78 @@
+// Package jobs runs pipelines in the background and keeps their status and results in a store
+package jobs
+
+import (
+	"time"
+
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/trace"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Finished reports whether a job in this status will not change anymore
+func (s Status) Finished() bool {
+	switch s {
+	case StatusSucceeded, StatusFailed, StatusCanceled:
+		return true
+	}
+	return false
+}
+
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+type Job struct {
+	ID     string `json:"id"`
+	Status Status `json:"status"`
+	// Owner is the name of the api key that submitted the job, empty without authentication
+	Owner    string         `json:"owner,omitempty"`
+	Progress Progress       `json:"progress"`
+	Result   map[string]any `json:"result,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	// Violation describes why the policy stopped the job
+	Violation *policy.Violation `json:"violation,omitempty"`
+	// Trace of the executed steps, for jobs submitted with tracing
+	Trace        *trace.Node `json:"trace,omitempty"`
+	TraceSummary string      `json:"trace_summary,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	StartedAt    *time.Time  `json:"started_at,omitempty"`
+	FinishedAt   *time.Time  `json:"finished_at,omitempty"`
+}
+
+// Filter selects jobs in Store.List, zero fields match everything
+type Filter struct {
+	// Owner selects the jobs of an api key
+	Owner  string
+	Status Status
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// Match reports whether the job passes the filter, Limit is applied by the store
+func (f Filter) Match(job Job) bool {
+	if f.Owner != "" && job.Owner != f.Owner {
+		return false
+	}
+	if f.Status != "" && job.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && job.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && job.CreatedAt.After(f.Until) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,295 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/metrics"
+	"github.com/fmotalleb/scrapper-go/notify"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/telemetry"
+	"github.com/fmotalleb/scrapper-go/trace"
+)
+
+var ErrJobFinished = errors.New("job already finished")
+
+// Config of a job manager
+type Config struct {
+	Store Store
+	// Retention removes finished jobs older than this, zero keeps them forever
+	Retention time.Duration
+	// EngineOptions are passed to every execution (e.g. the browser pool)
+	EngineOptions []engine.Option
+	// ArtifactsDir keeps the failure artifacts of each job in a sub directory named by its id,
+	// artifacts are disabled when empty
+	ArtifactsDir string
+}
+
+// Manager runs jobs in the background and records their progress in the store
+type Manager struct {
+	cfg     Config
+	ctx     context.Context
+	stop    context.CancelFunc
+	lock    sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// NewManager creates a manager, jobs left unfinished by a previous process are marked as failed
+func NewManager(cfg Config) (*Manager, error) {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		cfg:     cfg,
+		ctx:     ctx,
+		stop:    stop,
+		cancels: make(map[string]context.CancelFunc),
+	}
+	if err := m.recover(); err != nil {
+		stop()
+		return nil, err
+	}
+	if cfg.Retention > 0 {
+		go m.purgeLoop()
+	}
+	return m, nil
+}
+
+// SubmitOptions customize a single job
+type SubmitOptions struct {
+	// MaxRuntime fails the job once it runs longer, zero means unlimited
+	MaxRuntime time.Duration
+	// Trace records the executed steps in the job
+	Trace bool
+	// TraceParent holds the span the spans of the job belong to, e.g. the span of the request submitting it
+	TraceParent context.Context
+	// Owner is recorded in the job, see Job.Owner
+	Owner string
+	// Options are added to the engine options of the manager for this job
+	Options []engine.Option
+	// Release is called once the job is finished, e.g. to free its slot in the limits of its api key
+	Release func()
+}
+
+// Submit stores a queued job and starts it in the background
+func (m *Manager) Submit(cfg config.ExecutionConfig, opts SubmitOptions) (Job, error) {
+	job := Job{
+		ID:        uuid.New().String(),
+		Status:    StatusQueued,
+		Owner:     opts.Owner,
+		CreatedAt: time.Now(),
+	}
+	if err := m.cfg.Store.Save(job); err != nil {
+		return Job{}, err
+	}
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	parent := telemetry.Detach(m.ctx, opts.TraceParent)
+	if opts.MaxRuntime > 0 {
+		ctx, cancel = context.WithTimeout(parent, opts.MaxRuntime)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	m.lock.Lock()
+	m.cancels[job.ID] = cancel
+	m.lock.Unlock()
+
+	go m.run(ctx, job.ID, cfg, opts)
+	slog.Info("job submitted", slog.String("job_id", job.ID))
+	return job, nil
+}
+
+// ArtifactsDir of the failure artifacts of a job, empty when artifacts are disabled
+func (m *Manager) ArtifactsDir(id string) string {
+	if m.cfg.ArtifactsDir == "" {
+		return ""
+	}
+	return filepath.Join(m.cfg.ArtifactsDir, filepath.Base(id))
+}
+
+func (m *Manager) Get(id string) (Job, error) {
+	return m.cfg.Store.Get(id)
+}
+
+func (m *Manager) List(filter Filter) ([]Job, error) {
+	return m.cfg.Store.List(filter)
+}
+
+// Cancel stops a queued or running job, the job is marked as canceled once the execution returns
+func (m *Manager) Cancel(id string) (Job, error) {
+	job, err := m.cfg.Store.Get(id)
+	if err != nil {
+		return Job{}, err
+	}
+	m.lock.Lock()
+	cancel, ok := m.cancels[id]
+	m.lock.Unlock()
+	if !ok || job.Status.Finished() {
+		return job, ErrJobFinished
+	}
+	slog.Info("canceling job", slog.String("job_id", id))
+	cancel()
+	return job, nil
+}
+
+// Close cancels every running job
+func (m *Manager) Close() {
+	m.stop()
+}
+
+func (m *Manager) run(ctx context.Context, id string, cfg config.ExecutionConfig, submit SubmitOptions) {
+	if submit.Release != nil {
+		defer submit.Release()
+	}
+	defer func() {
+		m.lock.Lock()
+		if cancel, ok := m.cancels[id]; ok {
+			cancel()
+			delete(m.cancels, id)
+		}
+		m.lock.Unlock()
+	}()
+
+	progress := engine.WithProgress(func(done, total int) {
+		m.update(id, func(job *Job) {
+			if job.StartedAt == nil {
+				now := time.Now()
+				job.StartedAt = &now
+				job.Status = StatusRunning
+			}
+			job.Progress = Progress{Done: done, Total: total}
+		})
+	})
+	opts := append(append([]engine.Option{}, m.cfg.EngineOptions...), submit.Options...)
+	opts = append(opts, progress, engine.WithArtifactsDir(m.ArtifactsDir(id)))
+	var tr *trace.Node
+	if submit.Trace {
+		tr = trace.New()
+		opts = append(opts, engine.WithTrace(tr))
+	}
+	result, err := engine.ExecuteConfig(ctx, cfg, opts...)
+
+	m.update(id, func(job *Job) {
+		now := time.Now()
+		job.FinishedAt = &now
+		if tr != nil {
+			job.Trace = tr
+			job.TraceSummary = tr.Summary()
+		}
+		// The result of failed jobs only holds its metadata, e.g. the paths of their recordings
+		job.Result = result
+		switch {
+		case err == nil:
+			job.Status = StatusSucceeded
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			job.Status = StatusFailed
+			job.Error = "max runtime exceeded"
+			metrics.Timeouts.Inc(metrics.TimeoutJob)
+		case m.ctx.Err() != nil:
+			job.Status = StatusCanceled
+			job.Error = "server shutting down"
+		case ctx.Err() != nil:
+			job.Status = StatusCanceled
+			job.Error = "canceled"
+		default:
+			job.Status = StatusFailed
+			job.Error = err.Error()
+			job.Violation, _ = policy.AsViolation(err)
+		}
+	})
+	// Canceled jobs are not reported
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		_ = notify.Dispatch(m.ctx, cfg.Pipeline.Notify, notify.NewEvent(result, err, nil))
+	}
+	if err != nil {
+		slog.Warn("job failed", slog.String("job_id", id), log.ErrVal(err))
+		return
+	}
+	slog.Info("job finished", slog.String("job_id", id))
+}
+
+// update applies fn to the stored job
+func (m *Manager) update(id string, fn func(*Job)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	job, err := m.cfg.Store.Get(id)
+	if err != nil {
+		slog.Error("failed to load job", slog.String("job_id", id), log.ErrVal(err))
+		return
+	}
+	fn(&job)
+	if err := m.cfg.Store.Save(job); err != nil {
+		slog.Error("failed to save job", slog.String("job_id", id), log.ErrVal(err))
+	}
+}
+
+// recover marks jobs that were queued or running when the previous process stopped as failed
+func (m *Manager) recover() error {
+	list, err := m.cfg.Store.List(Filter{})
+	if err != nil {
+		return err
+	}
+	for _, job := range list {
+		if job.Status.Finished() {
+			continue
+		}
+		now := time.Now()
+		job.Status = StatusFailed
+		job.Error = "interrupted by a server restart"
+		job.FinishedAt = &now
+		if err := m.cfg.Store.Save(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) purgeLoop() {
+	interval := min(m.cfg.Retention, time.Hour)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.purge(time.Now().Add(-m.cfg.Retention))
+		}
+	}
+}
+
+// purge deletes finished jobs created before the deadline
+func (m *Manager) purge(deadline time.Time) {
+	list, err := m.cfg.Store.List(Filter{Until: deadline})
+	if err != nil {
+		slog.Warn("failed to list jobs for retention", log.ErrVal(err))
+		return
+	}
+	for _, job := range list {
+		if !job.Status.Finished() {
+			continue
+		}
+		if err := m.cfg.Store.Delete(job.ID); err != nil {
+			slog.Warn("failed to delete expired job", slog.String("job_id", job.ID), log.ErrVal(err))
+		}
+		if dir := m.ArtifactsDir(job.ID); dir != "" {
+			if err := os.RemoveAll(dir); err != nil {
+				slog.Warn("failed to delete artifacts of expired job", slog.String("job_id", job.ID), log.ErrVal(err))
+			}
+		}
+	}
+}
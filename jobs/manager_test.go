@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine"
+)
+
+// waitingProvider never gives a browser, executions wait until they are canceled
+type waitingProvider struct{}
+
+func (waitingProvider) Acquire(ctx context.Context, _ string, _ playwright.BrowserTypeLaunchOptions) (playwright.Browser, func(), error) {
+	<-ctx.Done()
+	return nil, nil, ctx.Err()
+}
+
+// waitFinished polls the job until it is finished
+func waitFinished(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if job.Status.Finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestManagerCancel(t *testing.T) {
+	m, err := NewManager(Config{EngineOptions: []engine.Option{engine.WithBrowserProvider(waitingProvider{})}})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer m.Close()
+
+	var released atomic.Int32
+	cfg := config.ExecutionConfig{Pipeline: config.Pipeline{Browser: "chromium", Steps: []config.Step{{"nop": "x"}}}}
+	job, err := m.Submit(cfg, SubmitOptions{Owner: "alice", Release: func() { released.Add(1) }})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if job.Owner != "alice" || job.Status != StatusQueued {
+		t.Errorf("submitted job = %+v, expected a queued job of alice", job)
+	}
+	if list, _ := m.List(Filter{Owner: "bob"}); len(list) != 0 {
+		t.Errorf("jobs of bob = %v, expected none", list)
+	}
+	if released.Load() != 0 {
+		t.Error("job released before it finished")
+	}
+	if _, err := m.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	job = waitFinished(t, m, job.ID)
+	if job.Status != StatusCanceled || job.Owner != "alice" {
+		t.Errorf("canceled job = %+v, expected a canceled job of alice", job)
+	}
+	// The slot is released right after the job is stored as finished
+	for deadline := time.Now().Add(time.Second); released.Load() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if released.Load() != 1 {
+		t.Errorf("job released %d times, expected once", released.Load())
+	}
+	if _, err := m.Cancel(job.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("Cancel of a finished job = %v, expected %v", err, ErrJobFinished)
+	}
+	if _, err := m.Cancel("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel of a missing job = %v, expected %v", err, ErrNotFound)
+	}
+}
+
+func TestManagerMaxRuntime(t *testing.T) {
+	m, err := NewManager(Config{EngineOptions: []engine.Option{engine.WithBrowserProvider(waitingProvider{})}})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer m.Close()
+	cfg := config.ExecutionConfig{Pipeline: config.Pipeline{Browser: "chromium", Steps: []config.Step{{"nop": "x"}}}}
+	job, err := m.Submit(cfg, SubmitOptions{MaxRuntime: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if job = waitFinished(t, m, job.ID); job.Status != StatusFailed || job.Error != "max runtime exceeded" {
+		t.Errorf("job = %s (%s), expected it to exceed its max runtime", job.Status, job.Error)
+	}
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var ErrNotFound = errors.New("job not found")
+
+// Store keeps jobs, implementations must be safe for concurrent use
+type Store interface {
+	Save(job Job) error
+	Get(id string) (Job, error)
+	// List returns the jobs matching the filter, newest first
+	List(filter Filter) ([]Job, error)
+	Delete(id string) error
+}
+
+// MemoryStore keeps jobs in memory, they are lost on restart
+type MemoryStore struct {
+	lock sync.RWMutex
+	jobs map[string]Job
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job)}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(job Job) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.jobs[job.ID] = job
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(id string) (Job, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return job, nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(filter Filter) ([]Job, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	result := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if filter.Match(job) {
+			result = append(result, job)
+		}
+	}
+	return limitJobs(result, filter.Limit), nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+// limitJobs sorts the jobs newest first and keeps at most limit of them
+func limitJobs(list []Job, limit int) []Job {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
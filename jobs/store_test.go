@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStoreList(t *testing.T) {
+	start := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	saved := []Job{
+		{ID: "a1", Owner: "alice", Status: StatusSucceeded, CreatedAt: start},
+		{ID: "a2", Owner: "alice", Status: StatusFailed, CreatedAt: start.Add(time.Minute)},
+		{ID: "b1", Owner: "bob", Status: StatusSucceeded, CreatedAt: start.Add(2 * time.Minute)},
+		{ID: "n1", Status: StatusRunning, CreatedAt: start.Add(3 * time.Minute)},
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "everything", want: []string{"n1", "b1", "a2", "a1"}},
+		{name: "owner", filter: Filter{Owner: "alice"}, want: []string{"a2", "a1"}},
+		{name: "other owner", filter: Filter{Owner: "bob"}, want: []string{"b1"}},
+		{name: "unknown owner", filter: Filter{Owner: "carol"}, want: []string{}},
+		{name: "owner and status", filter: Filter{Owner: "alice", Status: StatusSucceeded}, want: []string{"a1"}},
+		{name: "since", filter: Filter{Since: start.Add(time.Minute)}, want: []string{"n1", "b1", "a2"}},
+		{name: "until", filter: Filter{Until: start.Add(time.Minute)}, want: []string{"a2", "a1"}},
+		{name: "limit", filter: Filter{Limit: 1}, want: []string{"n1"}},
+	}
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "file": fileStore} {
+		for _, job := range saved {
+			if err := store.Save(job); err != nil {
+				t.Fatalf("%s Save failed: %v", name, err)
+			}
+		}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				list, err := store.List(tt.filter)
+				if err != nil {
+					t.Fatalf("List failed: %v", err)
+				}
+				ids := make([]string, len(list))
+				for i, job := range list {
+					ids[i] = job.ID
+				}
+				if len(ids) != len(tt.want) {
+					t.Fatalf("List = %v, expected %v", ids, tt.want)
+				}
+				for i := range ids {
+					if ids[i] != tt.want[i] {
+						t.Fatalf("List = %v, expected %v", ids, tt.want)
+					}
+				}
+			})
+		}
+		if err := store.Delete("a1"); err != nil {
+			t.Fatalf("%s Delete failed: %v", name, err)
+		}
+		if _, err := store.Get("a1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s Get of a deleted job = %v, expected %v", name, err, ErrNotFound)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import "time"
+
+// Outcomes of runs and steps
+const (
+	OutcomeSuccess       = "success"
+	OutcomeFailure       = "failure"
+	OutcomeCanceled      = "canceled"
+	OutcomeLimitExceeded = "limit_exceeded"
+)
+
+// Sources of timeouts
+const (
+	TimeoutStep    = "step"    // a Playwright action timed out
+	TimeoutRun     = "run"     // a run reached the timeout of its limits
+	TimeoutSession = "session" // a session was idle for longer than its timeout
+	TimeoutJob     = "job"     // a job reached the max runtime of its api key
+)
+
+var (
+	Runs            = NewCounterVec("scrapper_runs_total", "Pipeline runs by outcome, each request of a session or a live stream counts as a run.", "outcome")
+	RunDuration     = NewHistogramVec("scrapper_run_duration_seconds", "Duration of pipeline runs (and session requests) by outcome.", nil, "outcome")
+	StepExecutions  = NewCounterVec("scrapper_step_executions_total", "Executed steps by type and outcome, nested steps included.", "type", "outcome")
+	StepDuration    = NewHistogramVec("scrapper_step_duration_seconds", "Duration of steps by type, blocks include their nested steps.", nil, "type")
+	BrowserLaunches = NewCounterVec("scrapper_browser_launches_total", "Browser launches by browser type and outcome.", "browser", "outcome")
+	Timeouts        = NewCounterVec("scrapper_timeouts_total", "Timeouts by source (step, run, session or job).", "source")
+)
+
+// Since is the number of seconds elapsed since start
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
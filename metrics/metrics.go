@@ -0,0 +1,254 @@
+// Package metrics collects counters, histograms and gauges of the server and the engine,
+// and writes them in the Prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets in seconds, from quick steps to long pipelines
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+type collector interface {
+	write(w io.Writer) error
+}
+
+var registry = struct {
+	lock       sync.Mutex
+	names      []string
+	collectors map[string]collector
+}{collectors: make(map[string]collector)}
+
+// register adds the collector, a collector registered again under the same name replaces the previous one
+func register(name string, c collector) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if _, ok := registry.collectors[name]; !ok {
+		registry.names = append(registry.names, name)
+		sort.Strings(registry.names)
+	}
+	registry.collectors[name] = c
+}
+
+// Write writes every registered metric, sorted by name
+func Write(w io.Writer) error {
+	registry.lock.Lock()
+	list := make([]collector, 0, len(registry.names))
+	for _, name := range registry.names {
+		list = append(list, registry.collectors[name])
+	}
+	registry.lock.Unlock()
+	for _, c := range list {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// series is the state of a metric shared by its label values
+type series[T any] struct {
+	name   string
+	help   string
+	labels []string
+	lock   sync.Mutex
+	values map[string]*T
+	keys   map[string][]string
+}
+
+func newSeries[T any](name, help string, labels []string) *series[T] {
+	return &series[T]{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*T),
+		keys:   make(map[string][]string),
+	}
+}
+
+// get returns the value of the label values, the caller must hold the lock
+func (s *series[T]) get(values []string) *T {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", s.name, len(s.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	value, ok := s.values[key]
+	if !ok {
+		value = new(T)
+		s.values[key] = value
+		s.keys[key] = append([]string{}, values...)
+	}
+	return value
+}
+
+// sorted calls fn for every label values, sorted, the caller must hold the lock
+func (s *series[T]) sorted(fn func(labels []string, value *T) error) error {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(s.keys[key], s.values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *series[T]) header(w io.Writer, kind string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", s.name, escapeHelp(s.help), s.name, kind)
+	return err
+}
+
+// CounterVec counts events by label values
+type CounterVec struct {
+	*series[float64]
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newSeries[float64](name, help, labels)}
+	register(name, c)
+	return c
+}
+
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	*c.get(values) += delta
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.header(w, "counter"); err != nil {
+		return err
+	}
+	return c.sorted(func(values []string, value *float64) error {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, values), formatValue(*value))
+		return err
+	})
+}
+
+// HistogramVec observes values, usually durations in seconds, by label values
+type HistogramVec struct {
+	*series[histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec creates a histogram, buckets are the sorted upper bounds (DefaultBuckets when nil)
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &HistogramVec{series: newSeries[histogram](name, help, labels), buckets: buckets}
+	register(name, h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	hist := h.get(values)
+	if hist.counts == nil {
+		hist.counts = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.sum += value
+	hist.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.header(w, "histogram"); err != nil {
+		return err
+	}
+	bucketLabels := append(append([]string{}, h.labels...), "le")
+	return h.sorted(func(values []string, hist *histogram) error {
+		for i, bound := range h.buckets {
+			labels := formatLabels(bucketLabels, append(append([]string{}, values...), formatValue(bound)))
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels, hist.counts[i]); err != nil {
+				return err
+			}
+		}
+		labels := formatLabels(bucketLabels, append(append([]string{}, values...), "+Inf"))
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels, hist.count); err != nil {
+			return err
+		}
+		labels = formatLabels(h.labels, values)
+		_, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.name, labels, formatValue(hist.sum), h.name, labels, hist.count)
+		return err
+	})
+}
+
+// gaugeFunc reads its value when the metrics are written
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+// RegisterGaugeFunc reports the value returned by fn, registering the name again replaces fn
+func RegisterGaugeFunc(name, help string, fn func() float64) {
+	register(name, &gaugeFunc{name: name, help: help, fn: fn})
+}
+
+func (g *gaugeFunc) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, escapeHelp(g.help), g.name, g.name, formatValue(g.fn()))
+	return err
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,186 @@
+// Package monitor compares the output of a pipeline with the output of its previous run
+package monitor
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// Diff of two outputs, keys configured with a key column are diffed row by row in Tables
+type Diff struct {
+	// Initial is set when there was no previous run, every key is reported as added
+	Initial bool                 `json:"initial,omitempty"`
+	Added   map[string]any       `json:"added,omitempty"`
+	Removed map[string]any       `json:"removed,omitempty"`
+	Updated map[string]Change    `json:"updated,omitempty"`
+	Tables  map[string]TableDiff `json:"tables,omitempty"`
+}
+
+type Change struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// TableDiff lists rows by the value of their key column
+type TableDiff struct {
+	Added   []any       `json:"added,omitempty"`
+	Removed []any       `json:"removed,omitempty"`
+	Changed []RowChange `json:"changed,omitempty"`
+}
+
+type RowChange struct {
+	Key string `json:"key"`
+	Old any    `json:"old"`
+	New any    `json:"new"`
+}
+
+// HasChanges reports whether the output differs from the previous run, the initial run has no changes
+func (d Diff) HasChanges() bool {
+	if d.Initial {
+		return false
+	}
+	return len(d.Added)+len(d.Removed)+len(d.Updated)+len(d.Tables) > 0
+}
+
+// Compare diffs the current output against the previous one, keys maps table keys to their key column
+func Compare(previous, current map[string]any, keys map[string]string) (Diff, error) {
+	diff := Diff{
+		Added:   make(map[string]any),
+		Removed: make(map[string]any),
+		Updated: make(map[string]Change),
+		Tables:  make(map[string]TableDiff),
+	}
+	if previous == nil {
+		diff.Initial = true
+	}
+	prev, err := normalize(previous)
+	if err != nil {
+		return diff, err
+	}
+	curr, err := normalize(current)
+	if err != nil {
+		return diff, err
+	}
+
+	for key, value := range curr {
+		old, ok := prev[key]
+		if !ok {
+			diff.Added[key] = value
+			continue
+		}
+		if reflect.DeepEqual(old, value) {
+			continue
+		}
+		if column, ok := keyColumn(keys, key); ok {
+			if table, ok := compareTables(old, value, column); ok {
+				// Reordered rows are not a change
+				if len(table.Added)+len(table.Removed)+len(table.Changed) > 0 {
+					diff.Tables[key] = table
+				}
+				continue
+			}
+		}
+		diff.Updated[key] = Change{Old: old, New: value}
+	}
+	for key, value := range prev {
+		if _, ok := curr[key]; !ok {
+			diff.Removed[key] = value
+		}
+	}
+	return diff, nil
+}
+
+// compareTables diffs two lists of rows by column, ok is false when either value is not a table
+func compareTables(old, current any, column string) (TableDiff, bool) {
+	oldRows, ok := indexRows(old, column)
+	if !ok {
+		return TableDiff{}, false
+	}
+	newRows, ok := indexRows(current, column)
+	if !ok {
+		return TableDiff{}, false
+	}
+
+	var table TableDiff
+	for _, key := range sortedKeys(newRows) {
+		row := newRows[key]
+		previous, ok := oldRows[key]
+		switch {
+		case !ok:
+			table.Added = append(table.Added, row)
+		case !reflect.DeepEqual(previous, row):
+			table.Changed = append(table.Changed, RowChange{Key: key, Old: previous, New: row})
+		}
+	}
+	for _, key := range sortedKeys(oldRows) {
+		if _, ok := newRows[key]; !ok {
+			table.Removed = append(table.Removed, oldRows[key])
+		}
+	}
+	return table, true
+}
+
+// indexRows maps the rows of a table by the value of their key column,
+// rows missing the column are identified by their whole content
+func indexRows(value any, column string) (map[string]any, bool) {
+	list, ok := value.([]any)
+	if !ok {
+		return nil, false
+	}
+	rows := make(map[string]any, len(list))
+	for _, item := range list {
+		row, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		key, ok := row[column]
+		if !ok {
+			key = row
+		}
+		rows[utils.ToString(key)] = row
+	}
+	return rows, true
+}
+
+// keyColumn finds the key column of a table, config keys are case-insensitive
+func keyColumn(keys map[string]string, key string) (string, bool) {
+	if column, ok := keys[key]; ok {
+		return column, true
+	}
+	for k, column := range keys {
+		if strings.EqualFold(k, key) {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// normalize round-trips the output through JSON, so fresh results compare equal to stored ones
+func normalize(output map[string]any) (map[string]any, error) {
+	if output == nil {
+		return map[string]any{}, nil
+	}
+	data, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := utils.ParseJSON(string(data))
+	if err != nil {
+		return nil, err
+	}
+	result, _ := parsed.(map[string]any)
+	return result, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
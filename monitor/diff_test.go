@@ -0,0 +1,149 @@
+package monitor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fmotalleb/scrapper-go/config"
+)
+
+func TestCompare(t *testing.T) {
+	rows := func(names ...string) []any {
+		list := make([]any, 0, len(names))
+		for i, name := range names {
+			list = append(list, map[string]any{"id": i, "name": name})
+		}
+		return list
+	}
+	tests := []struct {
+		name     string
+		previous map[string]any
+		current  map[string]any
+		keys     map[string]string
+		want     Diff
+		changed  bool
+	}{
+		{
+			name:    "initial run",
+			current: map[string]any{"title": "shop"},
+			want:    Diff{Initial: true, Added: map[string]any{"title": "shop"}},
+		},
+		{
+			name:     "unchanged",
+			previous: map[string]any{"title": "shop", "count": 2},
+			current:  map[string]any{"title": "shop", "count": 2},
+			want:     Diff{},
+		},
+		{
+			name:     "numbers compare equal to stored ones",
+			previous: map[string]any{"count": float64(2)},
+			current:  map[string]any{"count": 2},
+			want:     Diff{},
+		},
+		{
+			name:     "added removed and updated keys",
+			previous: map[string]any{"title": "shop", "old": true},
+			current:  map[string]any{"title": "store", "new": 1},
+			want: Diff{
+				Added:   map[string]any{"new": int64(1)},
+				Removed: map[string]any{"old": true},
+				Updated: map[string]Change{"title": {Old: "shop", New: "store"}},
+			},
+			changed: true,
+		},
+		{
+			name:     "table rows by key column",
+			previous: map[string]any{"rows": []any{map[string]any{"id": "a", "v": 1}, map[string]any{"id": "b", "v": 2}}},
+			current:  map[string]any{"rows": []any{map[string]any{"id": "b", "v": 3}, map[string]any{"id": "c", "v": 4}}},
+			keys:     map[string]string{"Rows": "id"},
+			want: Diff{Tables: map[string]TableDiff{"rows": {
+				Added:   []any{map[string]any{"id": "c", "v": int64(4)}},
+				Removed: []any{map[string]any{"id": "a", "v": int64(1)}},
+				Changed: []RowChange{{Key: "b", Old: map[string]any{"id": "b", "v": int64(2)}, New: map[string]any{"id": "b", "v": int64(3)}}},
+			}}},
+			changed: true,
+		},
+		{
+			name:     "reordered rows are not a change",
+			previous: map[string]any{"rows": []any{map[string]any{"name": "a"}, map[string]any{"name": "b"}}},
+			current:  map[string]any{"rows": []any{map[string]any{"name": "b"}, map[string]any{"name": "a"}}},
+			keys:     map[string]string{"rows": "name"},
+			want:     Diff{},
+		},
+		{
+			name:     "tables without key column are updated as a whole",
+			previous: map[string]any{"rows": rows("a")},
+			current:  map[string]any{"rows": rows("b")},
+			want: Diff{Updated: map[string]Change{"rows": {
+				Old: []any{map[string]any{"id": int64(0), "name": "a"}},
+				New: []any{map[string]any{"id": int64(0), "name": "b"}},
+			}}},
+			changed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compare(tt.previous, tt.current, tt.keys)
+			if err != nil {
+				t.Fatalf("Compare failed: %v", err)
+			}
+			want := withEmptyMaps(tt.want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Compare() = %+v, expected %+v", got, want)
+			}
+			if got.HasChanges() != tt.changed {
+				t.Errorf("HasChanges() = %v, expected %v", got.HasChanges(), tt.changed)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	cfg := config.Monitor{State: filepath.Join(t.TempDir(), "state", "monitor.json"), DiffOnly: true}
+
+	out, diff, err := Apply(cfg, map[string]any{"title": "shop"})
+	if err != nil {
+		t.Fatalf("first Apply failed: %v", err)
+	}
+	if !diff.Initial || out["initial"] != true || out["changed"] != false {
+		t.Errorf("first Apply = %v, expected the initial run", out)
+	}
+
+	out, diff, err = Apply(cfg, map[string]any{"title": "store"})
+	if err != nil {
+		t.Fatalf("second Apply failed: %v", err)
+	}
+	if diff.Initial || !diff.HasChanges() || out["changed"] != true {
+		t.Errorf("second Apply = %v, expected a change", out)
+	}
+	if change := diff.Updated["title"]; change.Old != "shop" || change.New != "store" {
+		t.Errorf("second Apply updated %+v, expected shop -> store", change)
+	}
+
+	cfg.DiffOnly = false
+	out, diff, err = Apply(cfg, map[string]any{"title": "store"})
+	if err != nil {
+		t.Fatalf("third Apply failed: %v", err)
+	}
+	if diff.HasChanges() || out["title"] != "store" {
+		t.Errorf("third Apply = %v with diff %+v, expected the unchanged output", out, diff)
+	}
+}
+
+// withEmptyMaps fills the maps Compare always allocates
+func withEmptyMaps(d Diff) Diff {
+	if d.Added == nil {
+		d.Added = map[string]any{}
+	}
+	if d.Removed == nil {
+		d.Removed = map[string]any{}
+	}
+	if d.Updated == nil {
+		d.Updated = map[string]Change{}
+	}
+	if d.Tables == nil {
+		d.Tables = map[string]TableDiff{}
+	}
+	return d
+}
@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+// state is the content of the monitor state file
+type state struct {
+	SavedAt time.Time      `json:"saved_at"`
+	Output  map[string]any `json:"output"`
+}
+
+// Check diffs the output against the one saved by the previous run, then saves it for the next run
+func Check(cfg config.Monitor, output map[string]any) (Diff, error) {
+	// Recordings get new paths on every run
+	output = utils.WithoutOutputMeta(output)
+	previous, err := load(cfg.State)
+	if err != nil {
+		return Diff{}, err
+	}
+	diff, err := Compare(previous, output, cfg.Keys)
+	if err != nil {
+		return Diff{}, fmt.Errorf("failed to compare outputs: %w", err)
+	}
+	if err := save(cfg.State, output); err != nil {
+		return Diff{}, err
+	}
+	return diff, nil
+}
+
+// Apply checks the output of a monitored run and returns what the run emits, either the diff or the output itself
+func Apply(cfg config.Monitor, output map[string]any) (map[string]any, Diff, error) {
+	diff, err := Check(cfg, output)
+	if err != nil {
+		return nil, diff, err
+	}
+	return emit(cfg, output, diff), diff, nil
+}
+
+func emit(cfg config.Monitor, output map[string]any, diff Diff) map[string]any {
+	if !cfg.DiffOnly {
+		return output
+	}
+	return map[string]any{
+		"initial": diff.Initial,
+		"changed": diff.HasChanges(),
+		"added":   diff.Added,
+		"removed": diff.Removed,
+		"updated": diff.Updated,
+		"tables":  diff.Tables,
+	}
+}
+
+func load(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read monitor state: %w", err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode monitor state: %w", err)
+	}
+	if s.Output == nil {
+		s.Output = map[string]any{}
+	}
+	return s.Output, nil
+}
+
+func save(path string, output map[string]any) error {
+	data, err := json.Marshal(state{SavedAt: time.Now(), Output: output})
+	if err != nil {
+		return fmt.Errorf("failed to encode monitor state: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create monitor state directory: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write monitor state: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,235 @@
+// Package notify calls the webhooks and commands configured for the end of a pipeline
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/monitor"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	// maxWebhookTimeout bounds webhooks whatever the timeout of their hook
+	maxWebhookTimeout = 5 * time.Minute
+	maxRedirects      = 10
+)
+
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Event describes how a pipeline finished, it is the default webhook body and the stdin of commands
+type Event struct {
+	Status  string         `json:"status"`
+	Result  map[string]any `json:"result,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Changed bool           `json:"changed"`
+	Diff    *monitor.Diff  `json:"diff,omitempty"`
+	Time    time.Time      `json:"time"`
+}
+
+// NewEvent builds the event of a run, diff is nil when the pipeline is not monitored
+func NewEvent(result map[string]any, err error, diff *monitor.Diff) Event {
+	event := Event{
+		Status: StatusSuccess,
+		Result: result,
+		Diff:   diff,
+		Time:   time.Now(),
+	}
+	if err != nil {
+		event.Status = StatusFailure
+		event.Error = err.Error()
+	}
+	if diff != nil {
+		event.Changed = diff.HasChanges()
+	}
+	return event
+}
+
+// Dispatch runs the hooks matching the event, failing hooks are logged and reported in the returned error
+// but never fail the pipeline
+func Dispatch(ctx context.Context, cfg config.Notify, event Event) error {
+	hooks := cfg.OnSuccess
+	if event.Status == StatusFailure {
+		hooks = cfg.OnFailure
+	}
+	if event.Changed {
+		hooks = append(append([]config.Hook{}, hooks...), cfg.OnChange...)
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	vars, err := eventVars(event)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, hook := range hooks {
+		if err := run(ctx, hook, event, vars); err != nil {
+			slog.Error("notification failed", slog.String("webhook", hook.Webhook), slog.Any("command", hook.Command), log.ErrVal(err))
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckRemote rejects the hooks of pipelines received by the api server: commands would run on the server
+// and webhooks reach any host it can, so webhooks are only accepted when the server allows them
+func CheckRemote(cfg config.Notify, allowWebhooks bool) error {
+	for _, hooks := range [][]config.Hook{cfg.OnSuccess, cfg.OnFailure, cfg.OnChange} {
+		for _, hook := range hooks {
+			if len(hook.Command) > 0 {
+				return errors.New("command hooks are only allowed in pipelines run from the command line or schedule files")
+			}
+			if hook.Webhook != "" && !allowWebhooks {
+				return errors.New("webhook hooks are disabled for api pipelines, start the server with --allow-webhooks to enable them")
+			}
+		}
+	}
+	return nil
+}
+
+func run(ctx context.Context, hook config.Hook, event Event, vars utils.Vars) error {
+	timeout := defaultTimeout
+	if hook.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(hook.Timeout); err != nil {
+			return fmt.Errorf("invalid hook timeout %q: %w", hook.Timeout, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch {
+	case hook.Webhook != "" && len(hook.Command) > 0:
+		return errors.New("a hook must have either a webhook or a command, not both")
+	case hook.Webhook != "":
+		return callWebhook(ctx, hook, event, vars)
+	case len(hook.Command) > 0:
+		return runCommand(ctx, hook, event, vars)
+	}
+	return errors.New("a hook must have a webhook or a command")
+}
+
+// webhookClient checks every redirect against the policy, so an allowed host cannot forward webhooks to denied ones
+var webhookClient = &http.Client{
+	Timeout:       maxWebhookTimeout,
+	CheckRedirect: checkRedirect,
+}
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return policy.Current().CheckURL(req.URL.String())
+}
+
+func callWebhook(ctx context.Context, hook config.Hook, event Event, vars utils.Vars) error {
+	url, err := utils.EvaluateTemplate(hook.Webhook, vars, nil)
+	if err != nil {
+		return err
+	}
+	if err := policy.Current().CheckURL(url); err != nil {
+		return err
+	}
+	body, err := webhookBody(hook, event, vars)
+	if err != nil {
+		return err
+	}
+	method := hook.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range hook.Headers {
+		rendered, err := utils.EvaluateTemplate(value, vars, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(key, rendered)
+	}
+
+	slog.Debug("calling webhook", slog.String("url", url), slog.String("status", event.Status))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded with %s: %s", resp.Status, snippet)
+	}
+	return nil
+}
+
+func webhookBody(hook config.Hook, event Event, vars utils.Vars) ([]byte, error) {
+	if hook.Body == "" {
+		return json.Marshal(event)
+	}
+	body, err := utils.EvaluateTemplate(hook.Body, vars, nil)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(body), nil
+}
+
+func runCommand(ctx context.Context, hook config.Hook, event Event, vars utils.Vars) error {
+	if err := policy.Current().CheckStep(policy.KindCommand); err != nil {
+		return err
+	}
+	args, err := utils.EvaluateTemplates(hook.Command, vars, nil)
+	if err != nil {
+		return err
+	}
+	input, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("running notification command", slog.Any("command", args), slog.String("status", event.Status))
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command failed: %w: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// eventVars exposes the event to templates as `.status`, `.result`, `.error`, `.changed` and `.diff`
+func eventVars(event Event) (utils.Vars, error) {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := utils.ParseJSON(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+	fields, _ := parsed.(map[string]any)
+	vars := make(utils.Vars)
+	for key, value := range fields {
+		vars.SetOnce(key, value)
+	}
+	return vars, nil
+}
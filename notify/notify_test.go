@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/policy"
+)
+
+func setPolicy(t *testing.T, cfg policy.Config) {
+	t.Helper()
+	p, err := policy.New(cfg)
+	if err != nil {
+		t.Fatalf("policy.New failed: %v", err)
+	}
+	policy.Set(p)
+	t.Cleanup(func() { policy.Set(nil) })
+}
+
+func TestWebhookRedirects(t *testing.T) {
+	var internalHits atomic.Int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHits.Add(1)
+	}))
+	defer internal.Close()
+	// The same server under a name the policy denies
+	internalURL, _ := url.Parse(internal.URL)
+	denied := "http://localhost:" + internalURL.Port()
+
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, denied+"/admin", http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	tests := []struct {
+		name    string
+		policy  policy.Config
+		webhook string
+		hits    int32
+		denied  bool
+	}{
+		{name: "redirect without policy", webhook: redirector.URL, hits: 1},
+		{name: "redirect to a denied host", policy: policy.Config{URLs: policy.URLRules{Deny: []string{"localhost"}}}, webhook: redirector.URL, denied: true},
+		{name: "denied webhook", policy: policy.Config{URLs: policy.URLRules{Deny: []string{"localhost"}}}, webhook: denied, denied: true},
+		{name: "allowed webhook", policy: policy.Config{URLs: policy.URLRules{Deny: []string{"localhost"}}}, webhook: internal.URL, hits: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setPolicy(t, tt.policy)
+			internalHits.Store(0)
+			hooks := config.Notify{OnSuccess: []config.Hook{{Webhook: tt.webhook, Method: http.MethodGet}}}
+			err := Dispatch(context.Background(), hooks, NewEvent(map[string]any{"title": "shop"}, nil, nil))
+			if _, ok := policy.AsViolation(err); ok != tt.denied {
+				t.Errorf("Dispatch = %v, expected a violation: %v", err, tt.denied)
+			}
+			if !tt.denied && err != nil {
+				t.Errorf("Dispatch failed: %v", err)
+			}
+			if hits := internalHits.Load(); hits != tt.hits {
+				t.Errorf("internal server got %d requests, expected %d", hits, tt.hits)
+			}
+		})
+	}
+}
+
+func TestWebhookRedirectLoop(t *testing.T) {
+	var loop *httptest.Server
+	loop = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, loop.URL, http.StatusFound)
+	}))
+	defer loop.Close()
+	hooks := config.Notify{OnFailure: []config.Hook{{Webhook: loop.URL}}}
+	err := Dispatch(context.Background(), hooks, NewEvent(nil, errors.New("failed"), nil))
+	if err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("Dispatch = %v, expected the redirects to be stopped", err)
+	}
+}
+
+func TestCheckRemote(t *testing.T) {
+	tests := []struct {
+		name          string
+		notify        config.Notify
+		allowWebhooks bool
+		valid         bool
+	}{
+		{name: "no hooks", valid: true},
+		{name: "command", notify: config.Notify{OnSuccess: []config.Hook{{Command: []string{"id"}}}}, allowWebhooks: true},
+		{name: "command on change", notify: config.Notify{OnChange: []config.Hook{{Command: []string{"id"}}}}, allowWebhooks: true},
+		{name: "webhook disabled", notify: config.Notify{OnFailure: []config.Hook{{Webhook: "https://example.com"}}}},
+		{name: "webhook allowed", notify: config.Notify{OnFailure: []config.Hook{{Webhook: "https://example.com"}}}, allowWebhooks: true, valid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckRemote(tt.notify, tt.allowWebhooks); (err == nil) != tt.valid {
+				t.Errorf("CheckRemote = %v, expected valid: %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"log/slog"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/log"
+)
+
+// guardPattern matches every request of a browser context
+const guardPattern = "**"
+
+// Guard makes the browser context abort requests to urls denied by the current policy.
+// Routes registered later run first, so Guard must be called again after adding or removing routes
+// to keep it ahead of them
+func Guard(ctx playwright.BrowserContext) error {
+	if !Current().HasURLRules() {
+		return nil
+	}
+	if err := ctx.Unroute(guardPattern, guardRoute); err != nil {
+		return err
+	}
+	return ctx.Route(guardPattern, guardRoute)
+}
+
+func guardRoute(route playwright.Route) {
+	url := route.Request().URL()
+	if err := Current().CheckURL(url); err != nil {
+		slog.Warn("request blocked by policy", slog.String("url", url), log.ErrVal(err))
+		if err := route.Abort("blockedbyclient"); err != nil {
+			slog.Warn("failed to abort request", slog.String("url", url), log.ErrVal(err))
+		}
+		return
+	}
+	if err := route.Fallback(); err != nil {
+		slog.Warn("failed to pass request on", slog.String("url", url), log.ErrVal(err))
+	}
+}
@@ -0,0 +1,156 @@
+// Package policy restricts what pipelines may do on the server: the urls they reach, the step kinds
+// and template functions they use and the size of their loops
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+)
+
+// Kinds that are not steps but can be disabled like them
+const (
+	// KindDownloads stops pages from accepting downloads
+	KindDownloads = "downloads"
+	// KindCommand stops notifications from running local commands
+	KindCommand = "command"
+	// FunctionPage hides the `.page` object from templates, disabling its methods (e.g. `.page.Evaluate`)
+	FunctionPage = "page"
+)
+
+// Config is the content of the policy file, empty fields do not restrict anything
+type Config struct {
+	URLs URLRules `mapstructure:"urls"`
+	// DisabledSteps lists step kinds (e.g. `eval`, `mouse`, `loop`) and `downloads`
+	DisabledSteps []string `mapstructure:"disabled_steps"`
+	// DisabledFunctions lists template functions (e.g. `eval`) and `page`
+	DisabledFunctions []string `mapstructure:"disabled_functions"`
+	// MaxLoopIterations bounds the iterations of every loop
+	MaxLoopIterations int `mapstructure:"max_loop_iterations"`
+}
+
+// URLRules restrict the urls pages navigate to or request,
+// hosts are domains (matching their subdomains too), `*.` wildcards, IPs or CIDRs
+type URLRules struct {
+	// Schemes allowed, e.g. `http` and `https`
+	Schemes []string `mapstructure:"schemes"`
+	// Allow restricts hosts to the listed ones
+	Allow []string `mapstructure:"allow"`
+	// Deny takes precedence over Allow
+	Deny []string `mapstructure:"deny"`
+}
+
+// Policy is a loaded policy, a nil policy allows everything
+type Policy struct {
+	cfg     Config
+	schemes map[string]bool
+	allow   []hostRule
+	deny    []hostRule
+}
+
+var current atomic.Pointer[Policy]
+
+// Set installs the policy applied to every execution of the process, nil removes it
+func Set(p *Policy) {
+	current.Store(p)
+}
+
+// Current returns the installed policy, nil when there is none
+func Current() *Policy {
+	return current.Load()
+}
+
+// Load reads the policy file, any format supported by viper (yaml, json, toml, ...) can be used
+func Load(file string) (*Policy, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	return New(cfg)
+}
+
+func New(cfg Config) (*Policy, error) {
+	if cfg.MaxLoopIterations < 0 {
+		return nil, fmt.Errorf("max_loop_iterations must not be negative, got: %d", cfg.MaxLoopIterations)
+	}
+	p := &Policy{cfg: cfg}
+	if len(cfg.URLs.Schemes) > 0 {
+		p.schemes = make(map[string]bool, len(cfg.URLs.Schemes))
+		for _, scheme := range cfg.URLs.Schemes {
+			p.schemes[strings.ToLower(strings.TrimSuffix(scheme, ":"))] = true
+		}
+	}
+	var err error
+	if p.allow, err = parseHostRules(cfg.URLs.Allow); err != nil {
+		return nil, fmt.Errorf("invalid url allow rule: %w", err)
+	}
+	if p.deny, err = parseHostRules(cfg.URLs.Deny); err != nil {
+		return nil, fmt.Errorf("invalid url deny rule: %w", err)
+	}
+	return p, nil
+}
+
+// CheckStep rejects disabled step kinds
+func (p *Policy) CheckStep(kind string) error {
+	if p == nil || !contains(p.cfg.DisabledSteps, kind) {
+		return nil
+	}
+	return &Violation{Rule: RuleStep, Subject: kind, Reason: "step kind is disabled"}
+}
+
+// CheckFunction rejects disabled template functions
+func (p *Policy) CheckFunction(name string) error {
+	if p == nil || !contains(p.cfg.DisabledFunctions, name) {
+		return nil
+	}
+	return &Violation{Rule: RuleFunction, Subject: name, Reason: "template function is disabled"}
+}
+
+// DisabledFunctions lists the template functions that must not be called
+func (p *Policy) DisabledFunctions() []string {
+	if p == nil {
+		return nil
+	}
+	return p.cfg.DisabledFunctions
+}
+
+// CheckLoop rejects loops running more iterations than allowed
+func (p *Policy) CheckLoop(iterations int) error {
+	if p == nil || p.cfg.MaxLoopIterations == 0 || iterations <= p.cfg.MaxLoopIterations {
+		return nil
+	}
+	return &Violation{
+		Rule:    RuleLoop,
+		Subject: fmt.Sprint(iterations),
+		Reason:  fmt.Sprintf("loops are limited to %d iterations", p.cfg.MaxLoopIterations),
+	}
+}
+
+// MaxLoopIterations returns the iterations limit of loops, zero when unlimited
+func (p *Policy) MaxLoopIterations() int {
+	if p == nil {
+		return 0
+	}
+	return p.cfg.MaxLoopIterations
+}
+
+// DownloadsDisabled reports whether pages must refuse downloads
+func (p *Policy) DownloadsDisabled() bool {
+	return p.CheckStep(KindDownloads) != nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const resolveTimeout = 5 * time.Second
+
+// hostRule is either a network or a domain, `*.` domains only match subdomains
+type hostRule struct {
+	prefix     netip.Prefix
+	domain     string
+	subdomains bool
+}
+
+func parseHostRules(rules []string) ([]hostRule, error) {
+	result := make([]hostRule, 0, len(rules))
+	for _, raw := range rules {
+		rule := strings.ToLower(strings.TrimSpace(raw))
+		switch {
+		case rule == "":
+			return nil, fmt.Errorf("empty host rule")
+		case strings.Contains(rule, "/"):
+			prefix, err := netip.ParsePrefix(rule)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", raw, err)
+			}
+			result = append(result, hostRule{prefix: prefix.Masked()})
+		default:
+			if addr, err := netip.ParseAddr(strings.Trim(rule, "[]")); err == nil {
+				result = append(result, hostRule{prefix: netip.PrefixFrom(addr, addr.BitLen())})
+				continue
+			}
+			domain, wildcard := strings.CutPrefix(rule, "*.")
+			result = append(result, hostRule{domain: strings.TrimSuffix(domain, "."), subdomains: wildcard})
+		}
+	}
+	return result, nil
+}
+
+func (r hostRule) isNetwork() bool {
+	return r.prefix.IsValid()
+}
+
+func (r hostRule) matchesName(host string) bool {
+	if r.isNetwork() {
+		return false
+	}
+	if host == r.domain {
+		return !r.subdomains
+	}
+	return strings.HasSuffix(host, "."+r.domain)
+}
+
+func (r hostRule) matchesAddr(addr netip.Addr) bool {
+	return r.isNetwork() && r.prefix.Contains(addr.Unmap())
+}
+
+// HasURLRules reports whether urls are restricted at all
+func (p *Policy) HasURLRules() bool {
+	return p != nil && (len(p.schemes) > 0 || len(p.allow) > 0 || len(p.deny) > 0)
+}
+
+// CheckURL rejects urls with a disallowed scheme or host, hostnames are resolved to match CIDR rules
+func (p *Policy) CheckURL(raw string) error {
+	if !p.HasURLRules() {
+		return nil
+	}
+	deny := func(reason string) error {
+		return &Violation{Rule: RuleURL, Subject: raw, Reason: reason}
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return deny("invalid url")
+	}
+	if len(p.schemes) > 0 && !p.schemes[strings.ToLower(u.Scheme)] {
+		return deny(fmt.Sprintf("scheme %q is not allowed", u.Scheme))
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		// about:blank, data: urls, ... only the scheme applies
+		return nil
+	}
+	for _, rule := range p.deny {
+		if rule.matchesName(host) {
+			return deny("host is denied")
+		}
+	}
+	addrs, err := p.resolve(host)
+	if err != nil {
+		return deny(fmt.Sprintf("host cannot be resolved: %v", err))
+	}
+	for _, rule := range p.deny {
+		for _, addr := range addrs {
+			if rule.matchesAddr(addr) {
+				return deny(fmt.Sprintf("address %s is denied", addr))
+			}
+		}
+	}
+	if len(p.allow) > 0 && !p.allowed(host, addrs) {
+		return deny("host is not in the allow list")
+	}
+	return nil
+}
+
+// allowed matches the host by name, or all of its addresses by network
+func (p *Policy) allowed(host string, addrs []netip.Addr) bool {
+	for _, rule := range p.allow {
+		if rule.matchesName(host) {
+			return true
+		}
+	}
+	if len(addrs) == 0 {
+		return false
+	}
+	for _, addr := range addrs {
+		inside := false
+		for _, rule := range p.allow {
+			if rule.matchesAddr(addr) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve returns the addresses of the host, hostnames are only looked up when there are network rules
+func (p *Policy) resolve(host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr.Unmap()}, nil
+	}
+	if !hasNetworkRule(p.allow) && !hasNetworkRule(p.deny) {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for i, ip := range ips {
+		ips[i] = ip.Unmap()
+	}
+	return ips, nil
+}
+
+func hasNetworkRule(rules []hostRule) bool {
+	for _, rule := range rules {
+		if rule.isNetwork() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package policy
+
+import "testing"
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   URLRules
+		url     string
+		allowed bool
+	}{
+		{name: "no rules", url: "http://10.0.0.1/admin", allowed: true},
+		{name: "scheme allowed", rules: URLRules{Schemes: []string{"https"}}, url: "https://example.com", allowed: true},
+		{name: "scheme denied", rules: URLRules{Schemes: []string{"https"}}, url: "http://example.com", allowed: false},
+		{name: "scheme with colon", rules: URLRules{Schemes: []string{"HTTPS:"}}, url: "https://example.com", allowed: true},
+		{name: "file scheme", rules: URLRules{Schemes: []string{"http", "https"}}, url: "file:///etc/passwd", allowed: false},
+		{name: "no host", rules: URLRules{Allow: []string{"example.com"}}, url: "about:blank", allowed: true},
+		{name: "domain", rules: URLRules{Allow: []string{"example.com"}}, url: "https://example.com/path", allowed: true},
+		{name: "subdomain of domain", rules: URLRules{Allow: []string{"example.com"}}, url: "https://shop.example.com", allowed: true},
+		{name: "suffix is not a subdomain", rules: URLRules{Allow: []string{"example.com"}}, url: "https://badexample.com", allowed: false},
+		{name: "other domain", rules: URLRules{Allow: []string{"example.com"}}, url: "https://example.org", allowed: false},
+		{name: "case and trailing dot", rules: URLRules{Allow: []string{"Example.COM"}}, url: "https://EXAMPLE.com./", allowed: true},
+		{name: "wildcard subdomain", rules: URLRules{Allow: []string{"*.example.com"}}, url: "https://a.b.example.com", allowed: true},
+		{name: "wildcard apex", rules: URLRules{Allow: []string{"*.example.com"}}, url: "https://example.com", allowed: false},
+		{name: "deny wins over allow", rules: URLRules{Allow: []string{"example.com"}, Deny: []string{"admin.example.com"}}, url: "https://admin.example.com", allowed: false},
+		{name: "deny only", rules: URLRules{Deny: []string{"example.com"}}, url: "https://example.org", allowed: true},
+		{name: "ip allowed", rules: URLRules{Allow: []string{"192.168.1.10"}}, url: "http://192.168.1.10:8080/", allowed: true},
+		{name: "cidr denied", rules: URLRules{Deny: []string{"10.0.0.0/8"}}, url: "http://10.1.2.3/", allowed: false},
+		{name: "cidr not matching", rules: URLRules{Deny: []string{"10.0.0.0/8"}}, url: "http://11.1.2.3/", allowed: true},
+		{name: "ipv4 mapped ipv6", rules: URLRules{Deny: []string{"127.0.0.0/8"}}, url: "http://[::ffff:127.0.0.1]/", allowed: false},
+		{name: "ipv6 cidr", rules: URLRules{Deny: []string{"fc00::/7"}}, url: "http://[fd00::1]/", allowed: false},
+		{name: "cidr allowed", rules: URLRules{Allow: []string{"192.168.0.0/16"}}, url: "http://192.168.3.4/", allowed: true},
+		{name: "outside allowed cidr", rules: URLRules{Allow: []string{"192.168.0.0/16"}}, url: "http://172.16.0.1/", allowed: false},
+		{name: "name resolved to denied address", rules: URLRules{Deny: []string{"127.0.0.0/8", "::1/128"}}, url: "http://localhost:8080/", allowed: false},
+		{name: "invalid url", rules: URLRules{Allow: []string{"example.com"}}, url: "http://[::1", allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(Config{URLs: tt.rules})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			err = p.CheckURL(tt.url)
+			if tt.allowed && err != nil {
+				t.Errorf("CheckURL(%q) = %v, expected the url to be allowed", tt.url, err)
+			}
+			if !tt.allowed {
+				if err == nil {
+					t.Fatalf("CheckURL(%q) succeeded, expected a violation", tt.url)
+				}
+				if _, ok := AsViolation(err); !ok {
+					t.Errorf("CheckURL(%q) = %v, expected a *Violation", tt.url, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseHostRulesErrors(t *testing.T) {
+	for _, rules := range [][]string{{""}, {"10.0.0.0/33"}, {"example.com/path"}} {
+		if _, err := New(Config{URLs: URLRules{Deny: rules}}); err == nil {
+			t.Errorf("New with deny %q succeeded, expected an error", rules)
+		}
+	}
+}
+
+func TestNilPolicy(t *testing.T) {
+	var p *Policy
+	if err := p.CheckURL("http://10.0.0.1/"); err != nil {
+		t.Errorf("nil policy CheckURL = %v, expected nil", err)
+	}
+	if err := p.CheckStep("eval"); err != nil {
+		t.Errorf("nil policy CheckStep = %v, expected nil", err)
+	}
+	if err := p.CheckFunction(FunctionPage); err != nil {
+		t.Errorf("nil policy CheckFunction = %v, expected nil", err)
+	}
+}
@@ -0,0 +1,35 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Rules reported by violations
+const (
+	RuleURL      = "url"
+	RuleStep     = "step"
+	RuleFunction = "function"
+	RuleLoop     = "loop"
+)
+
+// Violation is the error returned when a pipeline breaks the policy
+type Violation struct {
+	Rule string `json:"rule"`
+	// Subject is what was denied: the url, the step kind, the function name or the iterations
+	Subject string `json:"subject"`
+	Reason  string `json:"reason"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("policy violation: %s %q: %s", v.Rule, v.Subject, v.Reason)
+}
+
+// AsViolation finds the violation wrapped in err
+func AsViolation(err error) (*Violation, bool) {
+	var violation *Violation
+	if errors.As(err, &violation) {
+		return violation, true
+	}
+	return nil, false
+}
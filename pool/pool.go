@@ -0,0 +1,241 @@
+// Package pool keeps warm Playwright drivers and browsers shared between executions
+package pool
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/engine"
+	"github.com/fmotalleb/scrapper-go/log"
+)
+
+var errPoolClosed = errors.New("browser pool is closed")
+
+// Config of a browser pool
+type Config struct {
+	// MaxConcurrent limits the number of executions running at the same time, zero means no limit
+	MaxConcurrent int
+	// MaxUses recycles a browser after it was handed out this many times, zero means never
+	MaxUses int
+}
+
+// Pool hands out browsers keyed by browser type and launch parameters,
+// executions open their own contexts so they stay isolated from each other
+type Pool struct {
+	cfg     Config
+	slots   chan struct{}
+	lock    sync.Mutex
+	entries map[string]*entry
+	closed  bool
+	// inUse counts the executions holding a browser of the pool
+	inUse atomic.Int64
+}
+
+// Stats describe the utilization of a pool
+type Stats struct {
+	// Capacity is the maximum number of concurrent executions, zero means no limit
+	Capacity int
+	InUse    int
+	// Browsers is the number of browsers kept warm
+	Browsers int
+}
+
+type entry struct {
+	key      string
+	ready    chan struct{}
+	err      error
+	pw       *playwright.Playwright
+	browser  playwright.Browser
+	uses     int
+	active   int
+	retired  bool
+	shutdown sync.Once
+}
+
+// New creates an empty pool, browsers are launched on first use
+func New(cfg Config) *Pool {
+	p := &Pool{
+		cfg:     cfg,
+		entries: make(map[string]*entry),
+	}
+	if cfg.MaxConcurrent > 0 {
+		p.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return p
+}
+
+// Acquire implements engine.BrowserProvider.
+// It waits for a free slot, then returns a warm browser (launching one if needed)
+func (p *Pool) Acquire(ctx context.Context, browserType string, params playwright.BrowserTypeLaunchOptions) (playwright.Browser, func(), error) {
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+	freeSlot := func() {
+		if p.slots != nil {
+			<-p.slots
+		}
+	}
+
+	e, err := p.lease(browserType, params)
+	if err != nil {
+		freeSlot()
+		return nil, nil, err
+	}
+	p.inUse.Add(1)
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			p.inUse.Add(-1)
+			p.release(e)
+			freeSlot()
+		})
+	}
+	return e.browser, release, nil
+}
+
+func (p *Pool) Stats() Stats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return Stats{
+		Capacity: p.cfg.MaxConcurrent,
+		InUse:    int(p.inUse.Load()),
+		Browsers: len(p.entries),
+	}
+}
+
+// Close retires every browser, browsers still in use are closed once released
+func (p *Pool) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closed = true
+	for _, e := range p.entries {
+		p.retire(e)
+	}
+}
+
+func (p *Pool) lease(browserType string, params playwright.BrowserTypeLaunchOptions) (*entry, error) {
+	key, err := poolKey(browserType, params)
+	if err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil, errPoolClosed
+	}
+	e, ok := p.entries[key]
+	if ok && p.cfg.MaxUses > 0 && e.uses >= p.cfg.MaxUses {
+		slog.Info("recycling browser", slog.String("key", key), slog.Int("uses", e.uses))
+		p.retire(e)
+		ok = false
+	}
+	if !ok {
+		e = &entry{key: key, ready: make(chan struct{})}
+		p.entries[key] = e
+	}
+	e.uses++
+	e.active++
+	p.lock.Unlock()
+
+	if ok {
+		<-e.ready
+	} else {
+		p.launch(e, browserType, params)
+	}
+	if e.err != nil {
+		p.release(e)
+		return nil, e.err
+	}
+	return e, nil
+}
+
+// launch starts the driver and browser of an entry, a crashed browser is retired so the next lease gets a new one
+func (p *Pool) launch(e *entry, browserType string, params playwright.BrowserTypeLaunchOptions) {
+	defer close(e.ready)
+	slog.Info("launching pooled browser", slog.String("browser", browserType), slog.String("key", e.key))
+	pw, err := playwright.Run()
+	if err != nil {
+		e.err = fmt.Errorf("playwright startup failed: %w", err)
+		return
+	}
+	browser, err := engine.LaunchBrowser(pw, browserType, params)
+	if err != nil {
+		_ = pw.Stop()
+		e.err = err
+		return
+	}
+	e.pw, e.browser = pw, browser
+	browser.OnDisconnected(func(playwright.Browser) {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if !e.retired {
+			slog.Warn("pooled browser disconnected", slog.String("key", e.key))
+			p.retire(e)
+		}
+	})
+}
+
+func (p *Pool) release(e *entry) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	e.active--
+	if e.err != nil {
+		p.retire(e)
+		return
+	}
+	if e.retired && e.active == 0 {
+		go e.close()
+	}
+}
+
+// retire removes the entry from the pool, it is closed right away if no execution uses it.
+// The caller must hold the lock
+func (p *Pool) retire(e *entry) {
+	if p.entries[e.key] == e {
+		delete(p.entries, e.key)
+	}
+	e.retired = true
+	if e.active == 0 {
+		go e.close()
+	}
+}
+
+func (e *entry) close() {
+	e.shutdown.Do(func() {
+		<-e.ready
+		if e.browser != nil && e.browser.IsConnected() {
+			if err := e.browser.Close(); err != nil {
+				slog.Warn("failed to close pooled browser", log.ErrVal(err))
+			}
+		}
+		if e.pw != nil {
+			if err := e.pw.Stop(); err != nil {
+				slog.Warn("failed to stop pooled Playwright driver", log.ErrVal(err))
+			}
+		}
+	})
+}
+
+// poolKey identifies browsers that can be shared, they must have the same type and launch parameters
+func poolKey(browserType string, params playwright.BrowserTypeLaunchOptions) (string, error) {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash launch parameters: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return browserType + ":" + hex.EncodeToString(sum[:8]), nil
+}
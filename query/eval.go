@@ -0,0 +1,309 @@
+package query
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/utils"
+)
+
+type node interface {
+	eval(data map[string]any) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) eval(map[string]any) (any, error) {
+	return n.value, nil
+}
+
+// identNode reads a variable, dotted paths (`results.rows.0.name`) walk into lists and maps.
+// Unknown names evaluate as their own text.
+type identNode struct {
+	name string
+}
+
+func (n *identNode) lookup(data map[string]any) (any, bool) {
+	if value, ok := data[n.name]; ok {
+		return value, true
+	}
+	parts := strings.Split(n.name, ".")
+	if len(parts) == 1 {
+		return nil, false
+	}
+	var current any = data
+	for _, part := range parts {
+		switch c := current.(type) {
+		case map[string]any:
+			value, ok := c[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(c) {
+				return nil, false
+			}
+			current = c[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func (n *identNode) eval(data map[string]any) (any, error) {
+	if value, ok := n.lookup(data); ok {
+		return value, nil
+	}
+	slog.Debug("field not found, evaluating as a fixed value", slog.String("field", n.name))
+	return n.name, nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(data map[string]any) (any, error) {
+	values := make([]any, len(n.items))
+	for i, item := range n.items {
+		value, err := item.eval(data)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+type notNode struct {
+	inner node
+}
+
+func (n *notNode) eval(data map[string]any) (any, error) {
+	value, err := n.inner.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(data map[string]any) (any, error) {
+	left, err := n.left.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit
+	if truthy(left) == (n.op == "or") {
+		return n.op == "or", nil
+	}
+	right, err := n.right.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+// checkNode implements the postfix `exists` and `empty` checks
+type checkNode struct {
+	check  string
+	inner  node
+	column int
+}
+
+func (n *checkNode) eval(data map[string]any) (any, error) {
+	var value any
+	found := true
+	if ident, ok := n.inner.(*identNode); ok {
+		value, found = ident.lookup(data)
+	} else {
+		var err error
+		if value, err = n.inner.eval(data); err != nil {
+			return nil, err
+		}
+	}
+	switch n.check {
+	case "exists":
+		return found && value != nil, nil
+	case "empty":
+		return !found || isEmpty(value), nil
+	}
+	return nil, errorAt(n.column, "unknown check "+n.check)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+	column      int
+}
+
+func (n *compareNode) eval(data map[string]any) (any, error) {
+	left, err := n.left.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	op, ok := operators[n.op]
+	if !ok {
+		return nil, errorAt(n.column, "unknown operator "+n.op)
+	}
+	result, err := op(left, right)
+	if err != nil {
+		slog.Error("error evaluating operator", slog.String("op", n.op), log.ErrVal(err))
+		return nil, fmt.Errorf("column %d: %w", n.column, err)
+	}
+	slog.Debug("compared values", slog.Any("left", left), slog.String("op", n.op), slog.Any("right", right), slog.Bool("result", result))
+	return result, nil
+}
+
+type operator func(any, any) (bool, error)
+
+var operators = map[string]operator{
+	"is": func(a, b any) (bool, error) { return equal(a, b), nil },
+	">":  ordered(func(c int) bool { return c > 0 }),
+	">=": ordered(func(c int) bool { return c >= 0 }),
+	"<":  ordered(func(c int) bool { return c < 0 }),
+	"<=": ordered(func(c int) bool { return c <= 0 }),
+	// match takes the regex on the left, as the old `field match value` format always did
+	"match":    func(a, b any) (bool, error) { return matchRegex(a, b) },
+	"matches":  func(a, b any) (bool, error) { return matchRegex(b, a) },
+	"contains": func(a, b any) (bool, error) { return contains(a, b), nil },
+	"in":       func(a, b any) (bool, error) { return contains(b, a), nil },
+	"startsWith": func(a, b any) (bool, error) {
+		return strings.HasPrefix(utils.ToString(a), utils.ToString(b)), nil
+	},
+	"endsWith": func(a, b any) (bool, error) {
+		return strings.HasSuffix(utils.ToString(a), utils.ToString(b)), nil
+	},
+}
+
+func matchRegex(pattern, value any) (bool, error) {
+	r, err := regexp.Compile(utils.ToString(pattern))
+	if err != nil {
+		slog.Error("invalid regex", log.ErrVal(err), slog.Any("pattern", pattern))
+		return false, fmt.Errorf("invalid regex: %v", err)
+	}
+	return r.MatchString(utils.ToString(value)), nil
+}
+
+func ordered(test func(int) bool) operator {
+	return func(a, b any) (bool, error) {
+		return test(compare(a, b)), nil
+	}
+}
+
+// compare orders numbers numerically (numeric strings included) and anything else as strings
+func compare(a, b any) int {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(utils.ToString(a), utils.ToString(b))
+}
+
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+	if x, ok := a.(bool); ok {
+		y, err := cast.ToBoolE(b)
+		return err == nil && x == y
+	}
+	if y, ok := b.(bool); ok {
+		x, err := cast.ToBoolE(a)
+		return err == nil && x == y
+	}
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	return utils.ToString(a) == utils.ToString(b)
+}
+
+func contains(container, item any) bool {
+	switch c := container.(type) {
+	case []any:
+		for _, element := range c {
+			if equal(element, item) {
+				return true
+			}
+		}
+		return false
+	case map[string]any:
+		_, ok := c[utils.ToString(item)]
+		return ok
+	case nil:
+		return false
+	}
+	return strings.Contains(utils.ToString(container), utils.ToString(item))
+}
+
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case bool, nil:
+		return 0, false
+	case string:
+		num, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return num, err == nil
+	}
+	num, err := cast.ToFloat64E(value)
+	return num, err == nil
+}
+
+func isEmpty(value any) bool {
+	if value == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// truthy converts the result of an expression to a boolean
+func truthy(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err == nil {
+			return b
+		}
+		return v != ""
+	}
+	if num, ok := toNumber(value); ok {
+		return num != 0
+	}
+	return !isEmpty(value)
+}
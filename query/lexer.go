@@ -0,0 +1,149 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	number float64
+	column int
+}
+
+// ParseError reports an invalid query and the (1-based) column where it was found
+type ParseError struct {
+	Column  int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return "column " + strconv.Itoa(e.Column) + ": " + e.Message
+}
+
+func errorAt(column int, msg string) *ParseError {
+	return &ParseError{Column: column, Message: msg}
+}
+
+// symbolic operators, longest first
+var symbols = []string{"==", "!=", ">=", "<=", "&&", "||", ">", "<", "!"}
+
+// wordBreakers end a bare word
+const wordBreakers = `()[],'"=!<>`
+
+func tokenize(query string) ([]token, error) {
+	runes := []rune(query)
+	tokens := make([]token, 0)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		column := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", column: column})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", column: column})
+			i++
+		case r == '[':
+			tokens = append(tokens, token{kind: tokenLBracket, text: "[", column: column})
+			i++
+		case r == ']':
+			tokens = append(tokens, token{kind: tokenRBracket, text: "]", column: column})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", column: column})
+			i++
+		case r == '"' || r == '\'':
+			text, next, err := readString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, column: column})
+			i = next
+		default:
+			if sym := readSymbol(runes, i); sym != "" {
+				tokens = append(tokens, token{kind: tokenOperator, text: sym, column: column})
+				i += len([]rune(sym))
+				continue
+			}
+			word, next := readWord(runes, i)
+			if word == "" {
+				// a lone breaker that starts no symbol, e.g. `=`
+				if r == '=' {
+					return nil, errorAt(column, "unexpected '=', did you mean '=='?")
+				}
+				return nil, errorAt(column, fmt.Sprintf("unexpected %q", r))
+			}
+			tokens = append(tokens, wordToken(word, column))
+			i = next
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, column: len(runes) + 1}), nil
+}
+
+func readString(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				b.WriteRune(runes[i])
+			}
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+	return "", 0, errorAt(start+1, "unterminated string")
+}
+
+func readSymbol(runes []rune, start int) string {
+	rest := string(runes[start:])
+	for _, sym := range symbols {
+		if strings.HasPrefix(rest, sym) {
+			return sym
+		}
+	}
+	return ""
+}
+
+func readWord(runes []rune, start int) (string, int) {
+	i := start
+	for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(wordBreakers, runes[i]) {
+		i++
+	}
+	return string(runes[start:i]), i
+}
+
+func wordToken(word string, column int) token {
+	if !strings.ContainsRune("0123456789+-.", rune(word[0])) {
+		return token{kind: tokenWord, text: word, column: column}
+	}
+	if num, err := strconv.ParseFloat(word, 64); err == nil {
+		return token{kind: tokenNumber, text: word, number: num, column: column}
+	}
+	return token{kind: tokenWord, text: word, column: column}
+}
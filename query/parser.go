@@ -0,0 +1,233 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// binaryOperators maps every accepted spelling to its canonical operator
+var binaryOperators = map[string]string{
+	"is":         "is",
+	"==":         "is",
+	"!=":         "is not",
+	">":          ">",
+	">=":         ">=",
+	"<":          "<",
+	"<=":         "<=",
+	"match":      "match",
+	"matches":    "matches",
+	"contains":   "contains",
+	"in":         "in",
+	"startswith": "startsWith",
+	"endswith":   "endsWith",
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(query string) (node, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, errorAt(1, "empty expression")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errorAt(tok.column, fmt.Sprintf("unexpected %q", tok.text))
+	}
+	return root, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword reports whether the next token is one of the given (case-insensitive) keywords
+func (p *parser) keyword(words ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokenWord && tok.kind != tokenOperator {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(tok.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or", "||") {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and", "&&") {
+		p.advance()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.keyword("not", "!") {
+		p.advance()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner: inner}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	negate := false
+	switch {
+	case p.keyword("exists", "empty"):
+		p.advance()
+		return &checkNode{check: strings.ToLower(tok.text), inner: left, column: tok.column}, nil
+	case p.keyword("is"):
+		p.advance()
+		if p.keyword("not") {
+			p.advance()
+			negate = true
+		}
+		if p.keyword("empty") {
+			p.advance()
+			return wrapNot(&checkNode{check: "empty", inner: left, column: tok.column}, negate), nil
+		}
+		return p.parseRight("is", left, tok.column, negate)
+	case p.keyword("not"):
+		// `a not in b`, `a not contains b`, ...
+		p.advance()
+		negate = true
+		tok = p.peek()
+	}
+
+	op, ok := binaryOperators[strings.ToLower(tok.text)]
+	if !ok || (tok.kind != tokenWord && tok.kind != tokenOperator) {
+		if negate {
+			return nil, errorAt(tok.column, fmt.Sprintf("expected an operator after 'not', got %q", tok.text))
+		}
+		return left, nil
+	}
+	p.advance()
+	if op == "is not" {
+		op, negate = "is", !negate
+	}
+	return p.parseRight(op, left, tok.column, negate)
+}
+
+func (p *parser) parseRight(op string, left node, column int, negate bool) (node, error) {
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return wrapNot(&compareNode{op: op, left: left, right: right, column: column}, negate), nil
+}
+
+func wrapNot(n node, negate bool) node {
+	if negate {
+		return &notNode{inner: n}
+	}
+	return n
+}
+
+func (p *parser) parseOperand() (node, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokenString:
+		return &literalNode{value: tok.text}, nil
+	case tokenNumber:
+		return &literalNode{value: tok.number}, nil
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokenRParen {
+			return nil, errorAt(closing.column, fmt.Sprintf("expected ')' to close '(' at column %d", tok.column))
+		}
+		return inner, nil
+	case tokenLBracket:
+		return p.parseList(tok)
+	case tokenWord:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		return &identNode{name: tok.text}, nil
+	case tokenEOF:
+		return nil, errorAt(tok.column, "unexpected end of expression")
+	default:
+		return nil, errorAt(tok.column, fmt.Sprintf("unexpected %q", tok.text))
+	}
+}
+
+func (p *parser) parseList(open token) (node, error) {
+	list := &listNode{}
+	if p.peek().kind == tokenRBracket {
+		p.advance()
+		return list, nil
+	}
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+		switch tok := p.advance(); tok.kind {
+		case tokenComma:
+		case tokenRBracket:
+			return list, nil
+		default:
+			return nil, errorAt(tok.column, fmt.Sprintf("expected ',' or ']' in list opened at column %d", open.column))
+		}
+	}
+}
@@ -1,4 +1,12 @@
 // Package query contains core functionality of internal scripting engine
+//
+// A query is a boolean expression, e.g.:
+//
+//	status is "ok" and (retries < 3 or not banner exists)
+//	results.rows.0.name startsWith "ir" and area in [1, 2, 3]
+//
+// Unknown identifiers evaluate as their own text, so templated queries like
+// `{{ .count }} > 3` keep working after the template is rendered.
 package query
 
 import (
@@ -8,76 +16,126 @@ import (
 	"strings"
 
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/utils"
 )
 
-type operator func(string, string) (bool, error)
-
-var operators = map[string]operator{
-	"is": func(s1, s2 string) (bool, error) { return s1 == s2, nil },
-	"match": func(s1, s2 string) (bool, error) {
-		r, err := regexp.Compile(s1)
-		if err != nil {
-			slog.Error("invalid regex", log.ErrVal(err), slog.Any("pattern", s1))
-			return false, fmt.Errorf("invalid regex: %v", err)
-		}
-		return r.MatchString(s2), nil
-	},
-	"contains": func(s1, s2 string) (bool, error) {
-		slog.Debug("checking contains", slog.Any("s1", s1), slog.Any("s2", s2))
-		return strings.Contains(s1, s2), nil
+// legacyOperators are the operators of the old `field op value` format, they compare the field as text
+var legacyOperators = map[string]operator{
+	"is":    func(a, b any) (bool, error) { return utils.ToString(a) == utils.ToString(b), nil },
+	"match": operators["match"],
+	"contains": func(a, b any) (bool, error) {
+		return strings.Contains(utils.ToString(a), utils.ToString(b)), nil
 	},
 }
 
+// legacyKeywords turn the rest of a query into an expression
+var legacyKeywords = map[string]bool{
+	"and": true,
+	"or":  true,
+}
+
 type Query struct {
-	Field string
-	Op    string
-	Value string
+	source string
+	root   node
 }
 
+// ParseQuery parses a boolean expression, errors wrap a *ParseError pointing to the faulty column
 func ParseQuery(query string) (*Query, error) {
+	if legacy, ok := parseLegacy(query); ok && legacy.plain {
+		slog.Debug("query parsed using the legacy `field op value` format", slog.String("query", query))
+		return &Query{source: query, root: legacy}, nil
+	}
+	root, err := parse(query)
+	if err != nil {
+		legacy, ok := parseLegacy(query)
+		if !ok {
+			slog.Error("invalid query", slog.String("query", query), log.ErrVal(err))
+			return nil, fmt.Errorf("invalid query %q: %w", query, err)
+		}
+		slog.Debug("query parsed using the legacy `field op value` format", slog.String("query", query))
+		root = legacy
+	}
+
+	slog.Debug("parsed query", slog.String("query", query))
+	return &Query{source: query, root: root}, nil
+}
+
+// parseLegacy reads the old format, where the value is the (unquoted) rest of the query
+func parseLegacy(query string) (*legacyNode, bool) {
 	// Regex to split while keeping quoted values intact
 	re := regexp.MustCompile(`"([^"]*)"|\S+`)
 	matches := re.FindAllString(query, -1)
-
 	if len(matches) < 3 {
-		slog.Error("invalid query format", slog.String("query", query))
-		return nil, fmt.Errorf("invalid query format: %s", query)
+		return nil, false
+	}
+	if _, ok := legacyOperators[matches[1]]; !ok {
+		return nil, false
 	}
 
-	field := strings.Trim(matches[0], "\"") // Ensure field is stripped of quotes
-	op := matches[1]
-	value := strings.Trim(strings.Join(matches[2:], " "), "\"") // Ensure value is stripped
+	return &legacyNode{
+		field: strings.Trim(matches[0], "\""),
+		op:    matches[1],
+		value: strings.Trim(strings.Join(matches[2:], " "), "\""),
+		plain: isPlainText(strings.Join(matches[2:], " ")),
+	}, true
+}
 
-	if _, ok := operators[op]; !ok {
-		slog.Error("unsupported operator", slog.String("op", op))
-		return nil, fmt.Errorf("unsupported operator: %s", op)
+// isPlainText reports whether the value of a legacy query is unquoted text, which keeps its old meaning:
+// `status is not found` compares status with "not found". Quotes, lists, groups, operators, `and`/`or`
+// and the `empty` checks make it an expression instead
+func isPlainText(value string) bool {
+	if strings.ContainsAny(value, `"'()[],=!<>&|`) {
+		return false
+	}
+	words := strings.Fields(value)
+	for _, word := range words {
+		if legacyKeywords[strings.ToLower(word)] {
+			return false
+		}
+	}
+	switch strings.ToLower(strings.Join(words, " ")) {
+	case "empty", "not empty":
+		return false
 	}
+	return true
+}
 
-	slog.Debug("parsed query", slog.String("field", field), slog.String("operator", op), slog.String("value", value))
-	return &Query{Field: field, Op: op, Value: value}, nil
+// legacyNode evaluates the old `field op value` format as it always did, the field is read as text
+// (or is its own text when unknown) and the value is the literal rest of the query
+type legacyNode struct {
+	field string
+	op    string
+	value string
+	// plain values keep the old meaning even when they also parse as an expression
+	plain bool
 }
 
-func (q *Query) EvaluateQuery(data map[string]string) (bool, error) {
-	val, exists := data[q.Field]
-	if !exists {
-		slog.Info("field not found, evaluating as a fixed value", slog.String("field", q.Field))
-		val = q.Field
+func (n *legacyNode) eval(data map[string]any) (any, error) {
+	field, err := (&identNode{name: n.field}).eval(data)
+	if err != nil {
+		return nil, err
 	}
-
-	op, ok := operators[q.Op]
-	if !ok {
-		slog.Error("unknown operation", slog.String("op", q.Op))
-		return false, fmt.Errorf("unknown operation: %s", q.Op)
+	result, err := legacyOperators[n.op](field, n.value)
+	if err != nil {
+		slog.Error("error evaluating operator", slog.String("op", n.op), log.ErrVal(err))
+		return nil, err
 	}
+	slog.Debug("compared values", slog.Any("field", field), slog.String("op", n.op), slog.String("value", n.value), slog.Bool("result", result))
+	return result, nil
+}
 
-	slog.Debug("evaluating query", slog.String("field", q.Field), slog.String("value", val), slog.Any("operator", q.Op), slog.Any("query_value", q.Value))
-
-	result, err := op(val, q.Value)
+// EvaluateQuery tests the query against typed data
+func (q *Query) EvaluateQuery(data map[string]any) (bool, error) {
+	value, err := q.root.eval(data)
 	if err != nil {
-		slog.Error("error evaluating operator", slog.String("op", q.Op), log.ErrVal(err))
+		slog.Error("error evaluating query", slog.String("query", q.source), log.ErrVal(err))
 		return false, err
 	}
-
-	slog.Debug("query evaluation result", slog.String("field", q.Field), slog.Any("result", result))
+	result := truthy(value)
+	slog.Debug("query evaluation result", slog.String("query", q.source), slog.Bool("result", result))
 	return result, nil
 }
+
+func (q *Query) String() string {
+	return q.source
+}
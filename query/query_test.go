@@ -0,0 +1,169 @@
+package query
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+	}{
+		{query: "=", column: 1},
+		{query: "a = b", column: 3},
+		{query: "status = ok", column: 8},
+		{query: "", column: 1},
+		{query: "(a > b", column: 7},
+		{query: "a > ", column: 5},
+		{query: `a > "b`, column: 5},
+		{query: "[1, 2", column: 6},
+		{query: "a not b", column: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseQuery(tt.query)
+			if err == nil {
+				t.Fatalf("ParseQuery(%q) succeeded, expected an error", tt.query)
+			}
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseQuery(%q) = %v, expected a *ParseError", tt.query, err)
+			}
+			if parseErr.Column != tt.column {
+				t.Errorf("ParseQuery(%q) error at column %d, expected %d (%v)", tt.query, parseErr.Column, tt.column, err)
+			}
+		})
+	}
+}
+
+func TestEvaluateQuery(t *testing.T) {
+	data := map[string]any{
+		"status":  "ok",
+		"retries": 2,
+		"price":   "12.50",
+		"flag":    true,
+		"empty":   "",
+		"items":   []any{"a", "b", 3},
+		"results": map[string]any{
+			"rows": []any{map[string]any{"name": "iran"}},
+		},
+		"title": "^Shop",
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: `status is "ok"`, want: true},
+		{query: `status == "ok"`, want: true},
+		{query: `status != "ok"`, want: false},
+		{query: `status is not "ok"`, want: false},
+		{query: `retries < 3`, want: true},
+		{query: `retries >= 3`, want: false},
+		{query: `price > 9`, want: true},
+		{query: `price <= 12.5`, want: true},
+		{query: `flag`, want: true},
+		{query: `!flag`, want: false},
+		{query: `not flag or retries == 2`, want: true},
+		{query: `status is "ok" and (retries > 5 || flag)`, want: true},
+		{query: `status is "ok" && retries > 5`, want: false},
+		{query: `3 in items`, want: true},
+		{query: `"c" in items`, want: false},
+		{query: `"c" not in items`, want: true},
+		{query: `items contains "b"`, want: true},
+		{query: `area in [1, 2, 3]`, want: false},
+		{query: `2 in [1, 2, 3]`, want: true},
+		{query: `results.rows.0.name startsWith "ir"`, want: true},
+		{query: `results.rows.0.name endsWith "an"`, want: true},
+		{query: `results.rows.1.name exists`, want: false},
+		{query: `status exists`, want: true},
+		{query: `missing exists`, want: false},
+		{query: `empty empty`, want: true},
+		{query: `status is empty`, want: false},
+		{query: `status is not empty`, want: true},
+		{query: `status matches "^o"`, want: true},
+		{query: `status matches "^k"`, want: false},
+		{query: `status not matches "^k"`, want: true},
+		{query: `"o+" match "ooo"`, want: true},
+		{query: `title match "Shopping"`, want: true},
+		{query: `flag == true`, want: true},
+		{query: `missing == null`, want: false},
+		{query: `1 == 1.0`, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) failed: %v", tt.query, err)
+			}
+			got, err := q.EvaluateQuery(data)
+			if err != nil {
+				t.Fatalf("EvaluateQuery(%q) failed: %v", tt.query, err)
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateQuery(%q) = %v, expected %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLegacyQuery checks the old `field op value` format keeps the meaning it had before the expression language
+func TestLegacyQuery(t *testing.T) {
+	data := map[string]any{
+		"status":  "not found",
+		"pattern": "^ab+c$",
+		"text":    "hello big world",
+		"count":   3,
+		"item":    "x",
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: `status is not found`, want: true},
+		{query: `status is found`, want: false},
+		{query: `done is done`, want: true},
+		{query: `some is some text`, want: false},
+		{query: `count is 3`, want: true},
+		{query: `text contains big world`, want: true},
+		{query: `text contains small`, want: false},
+		// the field is the regex
+		{query: `pattern match abbbc`, want: true},
+		{query: `pattern match abd`, want: false},
+		{query: `^a.c$ match abc`, want: true},
+		{query: `"status" is not found`, want: true},
+		// a variable on the right is plain text
+		{query: `x is item`, want: false},
+		{query: `item is item`, want: false},
+		// invalid expressions fall back to the old format
+		{query: `text contains "big" world`, want: false},
+		{query: `text is it's`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) failed: %v", tt.query, err)
+			}
+			got, err := q.EvaluateQuery(data)
+			if err != nil {
+				t.Fatalf("EvaluateQuery(%q) failed: %v", tt.query, err)
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateQuery(%q) = %v, expected %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateQueryInvalidRegex(t *testing.T) {
+	for _, query := range []string{`status matches "("`, `"(" match status`, `pattern match abc`} {
+		q, err := ParseQuery(query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) failed: %v", query, err)
+		}
+		if _, err := q.EvaluateQuery(map[string]any{"status": "ok", "pattern": "("}); err == nil {
+			t.Errorf("EvaluateQuery(%q) succeeded, expected an invalid regex error", query)
+		}
+	}
+}
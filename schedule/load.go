@@ -0,0 +1,56 @@
+package schedule
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/fmotalleb/scrapper-go/config"
+)
+
+// LoadDir reads every YAML pipeline of dir that has a schedule, the file name (without extension) names the schedule
+func LoadDir(dir string) (map[string]config.ExecutionConfig, error) {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+
+	result := make(map[string]config.ExecutionConfig)
+	for _, file := range files {
+		cfg, err := loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Schedule.Cron == "" && cfg.Schedule.Every == "" {
+			slog.Warn("pipeline has no schedule, skipping", slog.String("file", file))
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if _, ok := result[name]; ok {
+			return nil, fmt.Errorf("duplicate schedule name %q (%s)", name, file)
+		}
+		result[name] = cfg
+	}
+	return result, nil
+}
+
+// loadFile decodes a pipeline file the same way the cli does
+func loadFile(file string) (config.ExecutionConfig, error) {
+	var cfg config.ExecutionConfig
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return cfg, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return cfg, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,230 @@
+// Package schedule runs pipelines periodically in serve mode
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/monitor"
+	"github.com/fmotalleb/scrapper-go/notify"
+)
+
+const defaultKeep = 10
+
+var ErrNotFound = errors.New("schedule not found")
+
+// Run is the outcome of a single scheduled execution
+type Run struct {
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Result     map[string]any `json:"result,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	// Changed is set when a monitored pipeline output differs from the previous run
+	Changed bool `json:"changed,omitempty"`
+}
+
+// Info describes a schedule and its latest runs, newest first
+type Info struct {
+	Name    string     `json:"name"`
+	Spec    string     `json:"spec"`
+	Jitter  string     `json:"jitter,omitempty"`
+	Running bool       `json:"running"`
+	Skipped int        `json:"skipped"`
+	NextRun *time.Time `json:"next_run,omitempty"`
+	Runs    []Run      `json:"runs"`
+}
+
+type scheduled struct {
+	name    string
+	spec    string
+	jitter  time.Duration
+	keep    int
+	cfg     config.ExecutionConfig
+	entryID cron.EntryID
+
+	lock    sync.Mutex
+	running bool
+	skipped int
+	runs    []Run
+}
+
+// Scheduler runs every loaded pipeline on its schedule, a run is skipped while the previous one is still running
+type Scheduler struct {
+	cron    *cron.Cron
+	opts    []engine.Option
+	ctx     context.Context
+	stop    context.CancelFunc
+	entries map[string]*scheduled
+}
+
+// New prepares the schedules of the given pipelines, opts are passed to every execution
+func New(pipelines map[string]config.ExecutionConfig, opts ...engine.Option) (*Scheduler, error) {
+	ctx, stop := context.WithCancel(context.Background())
+	s := &Scheduler{
+		cron:    cron.New(),
+		opts:    opts,
+		ctx:     ctx,
+		stop:    stop,
+		entries: make(map[string]*scheduled),
+	}
+	for name, cfg := range pipelines {
+		entry, err := newScheduled(name, cfg)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		if entry.entryID, err = s.cron.AddFunc(entry.spec, func() { s.execute(entry) }); err != nil {
+			stop()
+			return nil, fmt.Errorf("schedule %q: invalid spec %q: %w", name, entry.spec, err)
+		}
+		s.entries[name] = entry
+		slog.Info("pipeline scheduled", slog.String("name", name), slog.String("spec", entry.spec))
+	}
+	return s, nil
+}
+
+func newScheduled(name string, cfg config.ExecutionConfig) (*scheduled, error) {
+	sch := cfg.Schedule
+	entry := &scheduled{
+		name: name,
+		keep: sch.Keep,
+		cfg:  cfg,
+	}
+	if entry.keep <= 0 {
+		entry.keep = defaultKeep
+	}
+	switch {
+	case sch.Cron != "" && sch.Every != "":
+		return nil, fmt.Errorf("schedule %q: use either cron or every, not both", name)
+	case sch.Cron != "":
+		entry.spec = sch.Cron
+	default:
+		interval, err := time.ParseDuration(sch.Every)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("schedule %q: every must be a positive duration, got: %q", name, sch.Every)
+		}
+		entry.spec = "@every " + interval.String()
+	}
+	if sch.Jitter != "" {
+		jitter, err := time.ParseDuration(sch.Jitter)
+		if err != nil || jitter < 0 {
+			return nil, fmt.Errorf("schedule %q: jitter must be a positive duration, got: %q", name, sch.Jitter)
+		}
+		entry.jitter = jitter
+	}
+	return entry, nil
+}
+
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling new runs and cancels the running ones
+func (s *Scheduler) Stop() {
+	s.stop()
+	<-s.cron.Stop().Done()
+}
+
+// List returns every schedule sorted by name
+func (s *Scheduler) List() []Info {
+	result := make([]Info, 0, len(s.entries))
+	for _, entry := range s.entries {
+		result = append(result, s.info(entry))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (s *Scheduler) Get(name string) (Info, error) {
+	entry, ok := s.entries[name]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return s.info(entry), nil
+}
+
+func (s *Scheduler) info(entry *scheduled) Info {
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	info := Info{
+		Name:    entry.name,
+		Spec:    entry.spec,
+		Running: entry.running,
+		Skipped: entry.skipped,
+		Runs:    append([]Run{}, entry.runs...),
+	}
+	if entry.jitter > 0 {
+		info.Jitter = entry.jitter.String()
+	}
+	if next := s.cron.Entry(entry.entryID).Next; !next.IsZero() {
+		info.NextRun = &next
+	}
+	return info
+}
+
+func (s *Scheduler) execute(entry *scheduled) {
+	entry.lock.Lock()
+	if entry.running {
+		entry.skipped++
+		entry.lock.Unlock()
+		slog.Warn("previous run still in progress, skipping", slog.String("schedule", entry.name))
+		return
+	}
+	entry.running = true
+	entry.lock.Unlock()
+	defer func() {
+		entry.lock.Lock()
+		entry.running = false
+		entry.lock.Unlock()
+	}()
+
+	if entry.jitter > 0 {
+		delay := rand.N(entry.jitter)
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+
+	slog.Info("running scheduled pipeline", slog.String("schedule", entry.name))
+	run := Run{StartedAt: time.Now()}
+	result, err := engine.ExecuteConfig(s.ctx, entry.cfg, s.opts...)
+	run.FinishedAt = time.Now()
+	output := result
+	var diff *monitor.Diff
+	if err == nil && entry.cfg.Pipeline.Monitor.Enabled() {
+		var d monitor.Diff
+		if output, d, err = monitor.Apply(entry.cfg.Pipeline.Monitor, result); err == nil {
+			diff = &d
+			run.Changed = d.HasChanges()
+		}
+	}
+	if err != nil {
+		slog.Error("scheduled pipeline failed", slog.String("schedule", entry.name), log.ErrVal(err))
+		run.Error = err.Error()
+	}
+	// The output of failed runs only holds its metadata, e.g. the paths of their recordings
+	run.Result = output
+	_ = notify.Dispatch(s.ctx, entry.cfg.Pipeline.Notify, notify.NewEvent(result, err, diff))
+
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	entry.runs = append([]Run{run}, entry.runs...)
+	if len(entry.runs) > entry.keep {
+		entry.runs = entry.runs[:entry.keep]
+	}
+}
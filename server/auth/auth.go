@@ -0,0 +1,128 @@
+// Package auth authenticates api requests with keys loaded from a file and enforces their limits
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+const (
+	principalKey = "auth.principal"
+	// QueryParam carries the key of clients unable to set headers (e.g. browser websockets)
+	QueryParam = "api_key"
+)
+
+// Config is the content of the keys file
+type Config struct {
+	Keys []Key `mapstructure:"keys"`
+}
+
+// Authenticator checks the key of every request against the configured keys
+type Authenticator struct {
+	principals []*Principal
+}
+
+// Load reads the keys file, any format supported by viper (yaml, json, toml, ...) can be used
+func Load(file string) (*Authenticator, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	return New(cfg.Keys)
+}
+
+func New(keys []Key) (*Authenticator, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no api key configured")
+	}
+	a := new(Authenticator)
+	names := make(map[string]bool)
+	secrets := make(map[string]bool)
+	for index, key := range keys {
+		switch {
+		case key.Name == "":
+			return nil, fmt.Errorf("api key at index %d has no name", index)
+		case key.Key == "":
+			return nil, fmt.Errorf("api key %q has an empty key", key.Name)
+		case names[key.Name]:
+			return nil, fmt.Errorf("duplicate api key name %q", key.Name)
+		case secrets[key.Key]:
+			return nil, fmt.Errorf("api key %q reuses the key of another entry", key.Name)
+		case key.MaxSessions < 0 || key.MaxRuntime < 0:
+			return nil, fmt.Errorf("api key %q has negative limits", key.Name)
+		}
+		names[key.Name] = true
+		secrets[key.Key] = true
+		a.principals = append(a.principals, &Principal{Key: key})
+	}
+	return a, nil
+}
+
+// Middleware rejects requests without a valid key with 401,
+// the key is read from `Authorization: Bearer <key>`, `X-API-Key` or the `api_key` query parameter
+func (a *Authenticator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := requestKey(c.Request())
+			if token == "" {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return c.JSON(http.StatusUnauthorized, map[string]any{
+					"error": "missing api key, send it as a bearer token or in the X-API-Key header",
+				})
+			}
+			principal := a.find(token)
+			if principal == nil {
+				slog.Warn("request with an invalid api key", slog.String("path", c.Path()), slog.String("remote", c.RealIP()))
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return c.JSON(http.StatusUnauthorized, map[string]any{
+					"error": "invalid api key",
+				})
+			}
+			slog.Debug("request authenticated", slog.String("key", principal.Name), slog.String("path", c.Path()))
+			c.Set(principalKey, principal)
+			return next(c)
+		}
+	}
+}
+
+// find compares the token with every key in constant time
+func (a *Authenticator) find(token string) *Principal {
+	var found *Principal
+	for _, principal := range a.principals {
+		if subtle.ConstantTimeCompare([]byte(principal.Key.Key), []byte(token)) == 1 {
+			found = principal
+		}
+	}
+	return found
+}
+
+func requestKey(r *http.Request) string {
+	if header := r.Header.Get(echo.HeaderAuthorization); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get(QueryParam)
+}
+
+// FromContext returns the principal of an authenticated request, nil when authentication is disabled
+func FromContext(c echo.Context) *Principal {
+	principal, _ := c.Get(principalKey).(*Principal)
+	return principal
+}
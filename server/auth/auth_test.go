@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  []Key
+		valid bool
+	}{
+		{name: "no keys"},
+		{name: "valid", keys: []Key{{Name: "alice", Key: "a"}, {Name: "bob", Key: "b"}}, valid: true},
+		{name: "missing name", keys: []Key{{Key: "a"}}},
+		{name: "missing key", keys: []Key{{Name: "alice"}}},
+		{name: "duplicate name", keys: []Key{{Name: "alice", Key: "a"}, {Name: "alice", Key: "b"}}},
+		{name: "duplicate key", keys: []Key{{Name: "alice", Key: "a"}, {Name: "bob", Key: "a"}}},
+		{name: "negative limits", keys: []Key{{Name: "alice", Key: "a", MaxSessions: -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.keys); (err == nil) != tt.valid {
+				t.Errorf("New = %v, expected valid: %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a, err := New([]Key{{Name: "alice", Key: "alice-key"}, {Name: "bob", Key: "bob-key"}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	e := echo.New()
+	e.GET("/whoami", func(c echo.Context) error {
+		return c.String(http.StatusOK, FromContext(c).Owner())
+	}, a.Middleware())
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		query  string
+		status int
+		owner  string
+	}{
+		{name: "missing key", status: http.StatusUnauthorized},
+		{name: "bearer", header: echo.HeaderAuthorization, value: "Bearer alice-key", status: http.StatusOK, owner: "alice"},
+		{name: "bearer scheme is case insensitive", header: echo.HeaderAuthorization, value: "bearer bob-key", status: http.StatusOK, owner: "bob"},
+		{name: "other scheme", header: echo.HeaderAuthorization, value: "Basic alice-key", status: http.StatusUnauthorized},
+		{name: "header", header: "X-API-Key", value: "bob-key", status: http.StatusOK, owner: "bob"},
+		{name: "query", query: "?api_key=alice-key", status: http.StatusOK, owner: "alice"},
+		{name: "invalid key", header: "X-API-Key", value: "carol-key", status: http.StatusUnauthorized},
+		{name: "prefix of a key", header: "X-API-Key", value: "alice", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, expected %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != tt.owner {
+				t.Errorf("principal = %q, expected %q", rec.Body.String(), tt.owner)
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get(echo.HeaderWWWAuthenticate) == "" {
+				t.Error("unauthorized response without WWW-Authenticate")
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/policy"
+)
+
+var (
+	ErrTooManySessions = errors.New("too many concurrent sessions for this api key")
+	ErrStepNotAllowed  = errors.New("step type not allowed for this api key")
+)
+
+// Key is an api key and its limits, zero limits are unlimited
+type Key struct {
+	Name string `mapstructure:"name"`
+	Key  string `mapstructure:"key"`
+	// MaxSessions bounds the sessions, live streams and jobs open at the same time
+	MaxSessions int `mapstructure:"max_sessions"`
+	// MaxRuntime bounds executions, sessions are killed once they are open for this long
+	MaxRuntime time.Duration `mapstructure:"max_runtime"`
+	// AllowedSteps lists the step types (e.g. `goto`, `element`, `loop`) pipelines may use, empty allows all.
+	// Without `eval`, the `eval` template function and the `.page` object are disabled too
+	AllowedSteps []string `mapstructure:"allowed_steps"`
+}
+
+// Principal is an authenticated key, a nil principal (authentication disabled) has no limits
+type Principal struct {
+	Key
+
+	lock     sync.Mutex
+	sessions int
+}
+
+// Owner identifies the sessions and jobs created with the key, empty when authentication is disabled
+func (p *Principal) Owner() string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
+
+// Owns reports whether the session or job of owner was created with the key,
+// without authentication every session and job is accessible
+func (p *Principal) Owns(owner string) bool {
+	return p == nil || p.Name == owner
+}
+
+// AcquireSession reserves a session slot, release must be called once the session is closed
+func (p *Principal) AcquireSession() (release func(), err error) {
+	if p == nil || p.MaxSessions == 0 {
+		return func() {}, nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.sessions >= p.MaxSessions {
+		return nil, fmt.Errorf("%w (limit %d)", ErrTooManySessions, p.MaxSessions)
+	}
+	p.sessions++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.lock.Lock()
+			p.sessions--
+			p.lock.Unlock()
+		})
+	}, nil
+}
+
+// WithRuntime bounds the context by the max runtime of the key
+func (p *Principal) WithRuntime(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p == nil || p.MaxRuntime == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.MaxRuntime)
+}
+
+// Runtime returns the max runtime of the key, zero when unlimited
+func (p *Principal) Runtime() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return p.MaxRuntime
+}
+
+// DisabledFunctions lists the template functions running scripts like the `eval` step,
+// they are disabled for keys that are not allowed to use it
+func (p *Principal) DisabledFunctions() []string {
+	if p == nil || len(p.AllowedSteps) == 0 || slices.Contains(p.AllowedSteps, "eval") {
+		return nil
+	}
+	return []string{"eval", policy.FunctionPage}
+}
+
+// CheckSteps rejects steps, including the nested ones, whose type is not allowed for the key
+func (p *Principal) CheckSteps(list []config.Step) error {
+	if p == nil || len(p.AllowedSteps) == 0 {
+		return nil
+	}
+	return steps.Walk(list, func(step config.Step) error {
+		for _, name := range steps.TypesOf(step) {
+			if !slices.Contains(p.AllowedSteps, name) {
+				return fmt.Errorf("%w: %s", ErrStepNotAllowed, name)
+			}
+		}
+		return nil
+	})
+}
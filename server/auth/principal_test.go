@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/policy"
+)
+
+func TestPrincipalOwner(t *testing.T) {
+	alice := &Principal{Key: Key{Name: "alice"}}
+	var disabled *Principal
+	if alice.Owner() != "alice" || disabled.Owner() != "" {
+		t.Errorf("Owner = %q and %q, expected alice and an empty owner", alice.Owner(), disabled.Owner())
+	}
+	tests := []struct {
+		principal *Principal
+		owner     string
+		owns      bool
+	}{
+		{principal: alice, owner: "alice", owns: true},
+		{principal: alice, owner: "bob", owns: false},
+		{principal: alice, owner: "", owns: false},
+		{principal: disabled, owner: "alice", owns: true},
+		{principal: disabled, owner: "", owns: true},
+	}
+	for _, tt := range tests {
+		if owns := tt.principal.Owns(tt.owner); owns != tt.owns {
+			t.Errorf("%q.Owns(%q) = %v, expected %v", tt.principal.Owner(), tt.owner, owns, tt.owns)
+		}
+	}
+}
+
+func TestAcquireSession(t *testing.T) {
+	p := &Principal{Key: Key{Name: "alice", MaxSessions: 2}}
+	first, err := p.AcquireSession()
+	if err != nil {
+		t.Fatalf("AcquireSession failed: %v", err)
+	}
+	second, err := p.AcquireSession()
+	if err != nil {
+		t.Fatalf("AcquireSession failed: %v", err)
+	}
+	if _, err := p.AcquireSession(); !errors.Is(err, ErrTooManySessions) {
+		t.Fatalf("AcquireSession over the limit = %v, expected %v", err, ErrTooManySessions)
+	}
+	// Releasing twice frees a single slot
+	first()
+	first()
+	if _, err := p.AcquireSession(); err != nil {
+		t.Fatalf("AcquireSession after a release failed: %v", err)
+	}
+	if _, err := p.AcquireSession(); !errors.Is(err, ErrTooManySessions) {
+		t.Fatalf("AcquireSession = %v, expected a release to free one slot only", err)
+	}
+	second()
+	if _, err := p.AcquireSession(); err != nil {
+		t.Errorf("AcquireSession after a release failed: %v", err)
+	}
+
+	for _, unlimited := range []*Principal{nil, {Key: Key{Name: "bob"}}} {
+		for range 10 {
+			if _, err := unlimited.AcquireSession(); err != nil {
+				t.Fatalf("AcquireSession without limit failed: %v", err)
+			}
+		}
+	}
+}
+
+func TestWithRuntime(t *testing.T) {
+	limited := &Principal{Key: Key{Name: "alice", MaxRuntime: time.Minute}}
+	ctx, cancel := limited.WithRuntime(context.Background())
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("WithRuntime deadline = %v, %v, expected at most a minute", deadline, ok)
+	}
+	if limited.Runtime() != time.Minute {
+		t.Errorf("Runtime = %v, expected a minute", limited.Runtime())
+	}
+	for _, unlimited := range []*Principal{nil, {Key: Key{Name: "bob"}}} {
+		ctx, cancel := unlimited.WithRuntime(context.Background())
+		if _, ok := ctx.Deadline(); ok || unlimited.Runtime() != 0 {
+			t.Error("WithRuntime of a key without max runtime set a deadline")
+		}
+		cancel()
+		if ctx.Err() == nil {
+			t.Error("WithRuntime returned a context that cannot be canceled")
+		}
+	}
+}
+
+func TestCheckSteps(t *testing.T) {
+	steps := []config.Step{
+		{"goto": "https://example.com"},
+		{"loop": []any{1, 2}, "steps": []any{map[string]any{"eval": "document.title"}}},
+	}
+	tests := []struct {
+		name    string
+		allowed []string
+		valid   bool
+	}{
+		{name: "all steps allowed", valid: true},
+		{name: "every type listed", allowed: []string{"goto", "loop", "eval"}, valid: true},
+		{name: "nested step not allowed", allowed: []string{"goto", "loop"}},
+		{name: "block not allowed", allowed: []string{"goto", "eval"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Principal{Key: Key{Name: "alice", AllowedSteps: tt.allowed}}
+			err := p.CheckSteps(steps)
+			if tt.valid && err != nil {
+				t.Errorf("CheckSteps failed: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrStepNotAllowed) {
+				t.Errorf("CheckSteps = %v, expected %v", err, ErrStepNotAllowed)
+			}
+		})
+	}
+	var disabled *Principal
+	if err := disabled.CheckSteps(steps); err != nil {
+		t.Errorf("CheckSteps without authentication = %v, expected every step to be allowed", err)
+	}
+}
+
+func TestDisabledFunctions(t *testing.T) {
+	tests := []struct {
+		principal *Principal
+		want      []string
+	}{
+		{principal: nil},
+		{principal: &Principal{Key: Key{Name: "all steps"}}},
+		{principal: &Principal{Key: Key{Name: "eval", AllowedSteps: []string{"goto", "eval"}}}},
+		{principal: &Principal{Key: Key{Name: "no eval", AllowedSteps: []string{"goto"}}}, want: []string{"eval", policy.FunctionPage}},
+	}
+	for _, tt := range tests {
+		if got := tt.principal.DisabledFunctions(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DisabledFunctions of %q = %v, expected %v", tt.principal.Owner(), got, tt.want)
+		}
+	}
+}
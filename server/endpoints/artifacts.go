@@ -0,0 +1,75 @@
+package endpoints
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/fmotalleb/scrapper-go/artifacts"
+	"github.com/fmotalleb/scrapper-go/log"
+)
+
+// sessionArtifacts maps the id of a session to the directory of its failure artifacts
+var sessionArtifacts sync.Map
+
+// newSessionArtifacts returns a fresh artifacts directory for a session, empty when artifacts are disabled
+func newSessionArtifacts() string {
+	if deps.ArtifactsDir == "" {
+		return ""
+	}
+	return filepath.Join(deps.ArtifactsDir, "sessions", uuid.New().String())
+}
+
+func sessionArtifactsDir(id string) string {
+	dir, _ := sessionArtifacts.Load(id)
+	str, _ := dir.(string)
+	return str
+}
+
+// dropSessionArtifacts deletes the artifacts of a session once it ended
+func dropSessionArtifacts(id string) {
+	dir, ok := sessionArtifacts.LoadAndDelete(id)
+	if !ok || dir == "" {
+		return
+	}
+	if err := os.RemoveAll(dir.(string)); err != nil {
+		slog.Warn("failed to delete session artifacts", slog.String("id", id), log.ErrVal(err))
+	}
+}
+
+// serveManifest answers the manifest of the failure artifacts in dir
+func serveManifest(c echo.Context, id, dir string) error {
+	if dir == "" {
+		return c.JSON(http.StatusNotFound, map[string]any{"id": id, "error": "artifacts are disabled"})
+	}
+	manifest, err := artifacts.ReadManifest(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c.JSON(http.StatusNotFound, map[string]any{"id": id, "error": "no failure was captured"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, manifest)
+}
+
+// serveArtifact answers a file of the failure artifacts in dir, named by the wildcard of the route
+func serveArtifact(c echo.Context, id, dir string) error {
+	if dir == "" {
+		return c.JSON(http.StatusNotFound, map[string]any{"id": id, "error": "artifacts are disabled"})
+	}
+	file, err := artifacts.File(dir, c.Param("*"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+	}
+	if _, err := os.Stat(file); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]any{"id": id, "file": c.Param("*")})
+	}
+	return c.File(file)
+}
@@ -1,7 +1,23 @@
 // Package endpoints holds logic of api endpoint
 package endpoints
 
-import "github.com/labstack/echo/v4"
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine"
+	"github.com/fmotalleb/scrapper-go/engine/steps"
+	"github.com/fmotalleb/scrapper-go/jobs"
+	"github.com/fmotalleb/scrapper-go/notify"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/schedule"
+	"github.com/fmotalleb/scrapper-go/server/auth"
+	"github.com/fmotalleb/scrapper-go/telemetry"
+	"github.com/fmotalleb/scrapper-go/trace"
+)
 
 type endpoint struct {
 	method      string
@@ -12,12 +28,118 @@ type endpoint struct {
 
 var endpoints []endpoint
 
+// Dependencies are the shared services used by the endpoints
+type Dependencies struct {
+	// EngineOptions are passed to every execution started by the endpoints (e.g. the browser pool)
+	EngineOptions []engine.Option
+	Jobs          *jobs.Manager
+	// Schedules is nil when the server runs without scheduled pipelines
+	Schedules *schedule.Scheduler
+	// Auth is nil when the server runs without authentication
+	Auth *auth.Authenticator
+	// ArtifactsDir keeps the failure artifacts of sessions, they are disabled when empty
+	ArtifactsDir string
+	// AllowWebhooks accepts the webhook hooks of pipelines sent by clients, command hooks are always rejected
+	AllowWebhooks bool
+}
+
+var deps Dependencies
+
 func registerEndpoint(e endpoint) {
 	endpoints = append(endpoints, e)
 }
 
-func PopulateEndpoints(e *echo.Echo) {
+func PopulateEndpoints(e *echo.Echo, d Dependencies) {
+	deps = d
 	for _, i := range endpoints {
-		e.Add(i.method, i.path, i.handler, i.middlewares...)
+		middlewares := i.middlewares
+		if d.Auth != nil {
+			middlewares = append([]echo.MiddlewareFunc{d.Auth.Middleware()}, middlewares...)
+		}
+		middlewares = append([]echo.MiddlewareFunc{propagateTrace}, middlewares...)
+		e.Add(i.method, i.path, i.handler, middlewares...)
+	}
+}
+
+// propagateTrace continues the trace of the client, received in the `traceparent` header
+func propagateTrace(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		c.SetRequest(req.WithContext(telemetry.Extract(req.Context(), req.Header)))
+		return next(c)
+	}
+}
+
+// checkSteps applies the limits of the api key and the server policy to steps sent by a client
+func checkSteps(c echo.Context, list []config.Step) error {
+	if err := auth.FromContext(c).CheckSteps(list); err != nil {
+		return err
 	}
+	return steps.Walk(list, steps.CheckPolicy)
+}
+
+// checkHooks rejects the notify hooks a client may not use, see notify.CheckRemote
+func checkHooks(cfg config.Notify) error {
+	return notify.CheckRemote(cfg, deps.AllowWebhooks)
+}
+
+// badRequest answers requests whose pipeline is not accepted by the server
+func badRequest(c echo.Context, err error) error {
+	return c.JSON(http.StatusBadRequest, errorBody(err))
+}
+
+// forbidden answers requests denied by the limits of their api key or by the policy
+func forbidden(c echo.Context, err error) error {
+	return c.JSON(http.StatusForbidden, errorBody(err))
+}
+
+// errorBody describes the error, policy violations are reported under `violation`
+func errorBody(err error) map[string]any {
+	body := map[string]any{
+		"error": err.Error(),
+	}
+	if violation, ok := policy.AsViolation(err); ok {
+		body["violation"] = violation
+	}
+	return body
+}
+
+// traceRequested reports whether the client asked for the trace of the execution with `?trace=true`
+func traceRequested(c echo.Context) bool {
+	traced, _ := strconv.ParseBool(c.QueryParam("trace"))
+	return traced
+}
+
+// keyOptions are the engine options restricting the executions of the api key of the request
+func keyOptions(c echo.Context) []engine.Option {
+	if names := auth.FromContext(c).DisabledFunctions(); len(names) > 0 {
+		return []engine.Option{engine.WithDisabledFunctions(names...)}
+	}
+	return nil
+}
+
+// requestOptions are the engine options of the executions started by the request
+func requestOptions(c echo.Context) []engine.Option {
+	return withOptions(deps.EngineOptions, keyOptions(c)...)
+}
+
+// traceOptions adds a trace to the engine options when the client requested it, the trace is nil otherwise
+func traceOptions(c echo.Context) ([]engine.Option, *trace.Node) {
+	if !traceRequested(c) {
+		return requestOptions(c), nil
+	}
+	tr := trace.New()
+	return withOptions(requestOptions(c), engine.WithTrace(tr)), tr
+}
+
+// withOptions returns a copy of opts with the extra options, opts is shared by every request
+func withOptions(opts []engine.Option, extra ...engine.Option) []engine.Option {
+	return append(append([]engine.Option{}, opts...), extra...)
+}
+
+// withTrace adds the trace and its text summary to a response body
+func withTrace(body map[string]any, tr *trace.Node) map[string]any {
+	body["trace"] = tr
+	body["trace_summary"] = tr.Summary()
+	return body
 }
@@ -0,0 +1,255 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/artifacts"
+	"github.com/fmotalleb/scrapper-go/engine"
+	"github.com/fmotalleb/scrapper-go/jobs"
+	"github.com/fmotalleb/scrapper-go/server/auth"
+	"github.com/fmotalleb/scrapper-go/session"
+)
+
+const pipeline = `{"pipeline": {"browser": "chromium", "steps": [{"nop": "x"}]}}`
+
+// waitingProvider never gives a browser, jobs keep running until they are canceled
+type waitingProvider struct{}
+
+func (waitingProvider) Acquire(ctx context.Context, _ string, _ playwright.BrowserTypeLaunchOptions) (playwright.Browser, func(), error) {
+	<-ctx.Done()
+	return nil, nil, ctx.Err()
+}
+
+// newServer serves the endpoints for the keys alice, limited to one session or job at a time, and bob
+func newServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	authenticator, err := auth.New([]auth.Key{
+		{Name: "alice", Key: "alice-key", MaxSessions: 1},
+		{Name: "bob", Key: "bob-key"},
+	})
+	if err != nil {
+		t.Fatalf("auth.New failed: %v", err)
+	}
+	manager, err := jobs.NewManager(jobs.Config{
+		EngineOptions: []engine.Option{engine.WithBrowserProvider(waitingProvider{})},
+		ArtifactsDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("jobs.NewManager failed: %v", err)
+	}
+	t.Cleanup(manager.Close)
+	e := echo.New()
+	PopulateEndpoints(e, Dependencies{Jobs: manager, Auth: authenticator})
+	return e
+}
+
+func request(e *echo.Echo, method, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-API-Key", key)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// openSession opens a session of owner answering every batch with an empty result
+func openSession(t *testing.T, owner string) *session.Session {
+	t.Helper()
+	sess, err := session.Open(func(ctx context.Context, in <-chan engine.Batch) (<-chan map[string]any, error) {
+		out := make(chan map[string]any)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-in:
+					out <- map[string]any{}
+				}
+			}
+		}()
+		return out, nil
+	}, time.Minute, owner)
+	if err != nil {
+		t.Fatalf("session.Open failed: %v", err)
+	}
+	t.Cleanup(sess.Kill)
+	return sess
+}
+
+// writeManifest stores an empty manifest of failure artifacts in dir
+func writeManifest(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create %s: %v", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, artifacts.ManifestFile), []byte(`{"failures": []}`), 0o644); err != nil {
+		t.Fatalf("failed to write the manifest: %v", err)
+	}
+}
+
+func TestJobsOfOtherKeys(t *testing.T) {
+	e := newServer(t)
+	rec := request(e, http.MethodPost, "/jobs", "alice-key", pipeline)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /jobs = %d %s, expected %d", rec.Code, rec.Body, http.StatusAccepted)
+	}
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("invalid job: %v", err)
+	}
+	defer func() { _, _ = deps.Jobs.Cancel(job.ID) }()
+	writeManifest(t, deps.Jobs.ArtifactsDir(job.ID))
+
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: "/jobs/" + job.ID},
+		{method: http.MethodGet, path: "/jobs/" + job.ID + "/artifacts"},
+		{method: http.MethodGet, path: "/jobs/" + job.ID + "/artifacts/manifest.json"},
+		{method: http.MethodDelete, path: "/jobs/" + job.ID},
+	}
+	for _, tt := range tests {
+		if rec := request(e, tt.method, tt.path, "bob-key", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s by another key = %d, expected %d", tt.method, tt.path, rec.Code, http.StatusNotFound)
+		}
+	}
+	if got, _ := deps.Jobs.Get(job.ID); got.Status.Finished() {
+		t.Fatal("another key canceled the job")
+	}
+
+	for key, want := range map[string][]string{"alice-key": {job.ID}, "bob-key": {}} {
+		var list []jobs.Job
+		if err := json.Unmarshal(request(e, http.MethodGet, "/jobs", key, "").Body.Bytes(), &list); err != nil {
+			t.Fatalf("invalid job list: %v", err)
+		}
+		ids := make([]string, 0, len(list))
+		for _, job := range list {
+			ids = append(ids, job.ID)
+		}
+		if !slices.Equal(ids, want) {
+			t.Errorf("GET /jobs of %s = %v, expected %v", key, ids, want)
+		}
+	}
+
+	for _, path := range []string{"/jobs/" + job.ID, "/jobs/" + job.ID + "/artifacts", "/jobs/" + job.ID + "/artifacts/manifest.json"} {
+		if rec := request(e, http.MethodGet, path, "alice-key", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET %s by its key = %d, expected %d", path, rec.Code, http.StatusOK)
+		}
+	}
+	if rec := request(e, http.MethodDelete, "/jobs/"+job.ID, "alice-key", ""); rec.Code != http.StatusAccepted {
+		t.Errorf("DELETE /jobs/:id by its key = %d, expected %d", rec.Code, http.StatusAccepted)
+	}
+}
+
+func TestSessionsOfOtherKeys(t *testing.T) {
+	e := newServer(t)
+	alice := openSession(t, "alice")
+	bob := openSession(t, "bob")
+	dir := filepath.Join(t.TempDir(), alice.ID)
+	writeManifest(t, dir)
+	sessionArtifacts.Store(alice.ID, dir)
+	defer sessionArtifacts.Delete(alice.ID)
+
+	for key, want := range map[string]string{"alice-key": alice.ID, "bob-key": bob.ID} {
+		var ids []string
+		if err := json.Unmarshal(request(e, http.MethodGet, "/sessions", key, "").Body.Bytes(), &ids); err != nil {
+			t.Fatalf("invalid session list: %v", err)
+		}
+		if !slices.Equal(ids, []string{want}) {
+			t.Errorf("GET /sessions of %s = %v, expected %v", key, ids, []string{want})
+		}
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodPost, path: "/sessions/" + alice.ID, body: `[{"nop": "x"}]`},
+		{method: http.MethodGet, path: "/sessions/" + alice.ID + "/artifacts"},
+		{method: http.MethodGet, path: "/sessions/" + alice.ID + "/artifacts/manifest.json"},
+		{method: http.MethodDelete, path: "/sessions/" + alice.ID},
+	}
+	for _, tt := range tests {
+		if rec := request(e, tt.method, tt.path, "bob-key", tt.body); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s by another key = %d, expected %d", tt.method, tt.path, rec.Code, http.StatusNotFound)
+		}
+	}
+	select {
+	case <-alice.Done():
+		t.Fatal("another key killed the session")
+	default:
+	}
+
+	for _, path := range []string{"/sessions/" + alice.ID + "/artifacts", "/sessions/" + alice.ID + "/artifacts/manifest.json"} {
+		if rec := request(e, http.MethodGet, path, "alice-key", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET %s by its key = %d, expected %d", path, rec.Code, http.StatusOK)
+		}
+	}
+	if rec := request(e, http.MethodPost, "/sessions/"+alice.ID, "alice-key", `[{"nop": "x"}]`); rec.Code != http.StatusOK {
+		t.Errorf("POST /sessions/:id by its key = %d %s, expected %d", rec.Code, rec.Body, http.StatusOK)
+	}
+	if rec := request(e, http.MethodDelete, "/sessions/"+alice.ID, "alice-key", ""); rec.Code != http.StatusOK {
+		t.Errorf("DELETE /sessions/:id by its key = %d, expected %d", rec.Code, http.StatusOK)
+	}
+	select {
+	case <-alice.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("the session was not killed by its key")
+	}
+}
+
+func TestSessionCap(t *testing.T) {
+	e := newServer(t)
+	rec := request(e, http.MethodPost, "/jobs", "alice-key", pipeline)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /jobs = %d %s, expected %d", rec.Code, rec.Body, http.StatusAccepted)
+	}
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("invalid job: %v", err)
+	}
+
+	// The running job holds the only slot of alice, other keys are not affected
+	for _, path := range []string{"/jobs", "/sessions"} {
+		if rec := request(e, http.MethodPost, path, "alice-key", pipeline); rec.Code != http.StatusForbidden {
+			t.Errorf("POST %s over the cap = %d %s, expected %d", path, rec.Code, rec.Body, http.StatusForbidden)
+		}
+	}
+	rec = request(e, http.MethodPost, "/jobs", "bob-key", pipeline)
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("POST /jobs of another key = %d %s, expected %d", rec.Code, rec.Body, http.StatusAccepted)
+	}
+	var other jobs.Job
+	_ = json.Unmarshal(rec.Body.Bytes(), &other)
+	defer func() { _, _ = deps.Jobs.Cancel(other.ID) }()
+
+	// Canceling the job frees its slot
+	if rec := request(e, http.MethodDelete, "/jobs/"+job.ID, "alice-key", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("DELETE /jobs/:id = %d, expected %d", rec.Code, http.StatusAccepted)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rec = request(e, http.MethodPost, "/jobs", "alice-key", pipeline)
+		if rec.Code != http.StatusForbidden {
+			break
+		}
+	}
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /jobs after the cancel = %d %s, expected %d", rec.Code, rec.Body, http.StatusAccepted)
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &job)
+	_, _ = deps.Jobs.Cancel(job.ID)
+}
@@ -0,0 +1,56 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fmotalleb/scrapper-go/jobs"
+)
+
+func init() {
+	registerEndpoint(
+		endpoint{
+			method:  "GET",
+			path:    "/jobs/:id/artifacts",
+			handler: jobsArtifacts,
+		},
+	)
+	registerEndpoint(
+		endpoint{
+			method:  "GET",
+			path:    "/jobs/:id/artifacts/*",
+			handler: jobsArtifactFile,
+		},
+	)
+}
+
+func jobsArtifacts(c echo.Context) error {
+	return withJob(c, func(id string) error {
+		return serveManifest(c, id, deps.Jobs.ArtifactsDir(id))
+	})
+}
+
+func jobsArtifactFile(c echo.Context) error {
+	return withJob(c, func(id string) error {
+		return serveArtifact(c, id, deps.Jobs.ArtifactsDir(id))
+	})
+}
+
+// withJob calls handle with the id of an existing job
+func withJob(c echo.Context, handle func(id string) error) error {
+	id := c.Param("id")
+	_, err := getJob(c, id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]any{
+			"id": id,
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+	}
+	return handle(id)
+}
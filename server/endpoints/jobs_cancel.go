@@ -0,0 +1,50 @@
+package endpoints
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fmotalleb/scrapper-go/jobs"
+)
+
+func init() {
+	registerEndpoint(
+		endpoint{
+			method:  "DELETE",
+			path:    "/jobs/:id",
+			handler: jobsCancel,
+		},
+	)
+}
+
+func jobsCancel(c echo.Context) error {
+	id := c.Param("id")
+	slog.Info("job cancel requested", slog.String("id", id))
+
+	job, err := getJob(c, id)
+	if err == nil {
+		job, err = deps.Jobs.Cancel(id)
+	}
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		return c.JSON(http.StatusNotFound, map[string]any{
+			"id": id,
+		})
+	case errors.Is(err, jobs.ErrJobFinished):
+		return c.JSON(http.StatusConflict, map[string]any{
+			"id":     id,
+			"status": job.Status,
+			"error":  err.Error(),
+		})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusAccepted, map[string]any{
+		"id": id,
+	})
+}
@@ -0,0 +1,69 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/jobs"
+	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/server/auth"
+)
+
+func init() {
+	registerEndpoint(
+		endpoint{
+			method:  "POST",
+			path:    "/jobs",
+			handler: jobsCreate,
+		},
+	)
+}
+
+func jobsCreate(c echo.Context) error {
+	cfgMap := make(map[string]any)
+	if err := json.NewDecoder(c.Request().Body).Decode(&cfgMap); err != nil {
+		slog.Error("failed to body", log.ErrVal(err))
+		return c.JSON(http.StatusBadRequest, map[string]any{
+			"error": "cannot unmarshal the given json body",
+		})
+	}
+	var cfg config.ExecutionConfig
+	if err := mapstructure.Decode(cfgMap, &cfg); err != nil {
+		slog.Error("failed to map config structure", log.ErrVal(err))
+		return c.JSON(http.StatusBadRequest, map[string]any{
+			"error": "Invalid configuration structure: " + err.Error(),
+		})
+	}
+	if err := checkHooks(cfg.Pipeline.Notify); err != nil {
+		return badRequest(c, err)
+	}
+	if err := checkSteps(c, cfg.Pipeline.Steps); err != nil {
+		return forbidden(c, err)
+	}
+	principal := auth.FromContext(c)
+	release, err := principal.AcquireSession()
+	if err != nil {
+		return forbidden(c, err)
+	}
+	job, err := deps.Jobs.Submit(cfg, jobs.SubmitOptions{
+		MaxRuntime:  principal.Runtime(),
+		Trace:       traceRequested(c),
+		TraceParent: c.Request().Context(),
+		Owner:       principal.Owner(),
+		Options:     keyOptions(c),
+		Release:     release,
+	})
+	if err != nil {
+		release()
+		slog.Error("failed to submit job", log.ErrVal(err))
+		return c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": "Failed to submit job: " + err.Error(),
+		})
+	}
+	return c.JSON(http.StatusAccepted, job)
+}
@@ -0,0 +1,46 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fmotalleb/scrapper-go/jobs"
+	"github.com/fmotalleb/scrapper-go/server/auth"
+)
+
+func init() {
+	registerEndpoint(
+		endpoint{
+			method:  "GET",
+			path:    "/jobs/:id",
+			handler: jobsGet,
+		},
+	)
+}
+
+func jobsGet(c echo.Context) error {
+	id := c.Param("id")
+	job, err := getJob(c, id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]any{
+			"id": id,
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+// getJob loads a job submitted with the api key of the request, the jobs of other keys are not found
+func getJob(c echo.Context, id string) (jobs.Job, error) {
+	job, err := deps.Jobs.Get(id)
+	if err == nil && !auth.FromContext(c).Owns(job.Owner) {
+		return jobs.Job{}, jobs.ErrNotFound
+	}
+	return job, err
+}
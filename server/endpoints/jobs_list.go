@@ -0,0 +1,60 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fmotalleb/scrapper-go/jobs"
+	"github.com/fmotalleb/scrapper-go/server/auth"
+)
+
+func init() {
+	registerEndpoint(
+		endpoint{
+			method:  "GET",
+			path:    "/jobs",
+			handler: jobsList,
+		},
+	)
+}
+
+// jobsList lists the jobs of the api key, it supports `status`, `since`, `until` (RFC3339) and `limit` query filters
+func jobsList(c echo.Context) error {
+	filter := jobs.Filter{
+		Owner:  auth.FromContext(c).Owner(),
+		Status: jobs.Status(c.QueryParam("status")),
+	}
+	var err error
+	if since := c.QueryParam("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]any{
+				"error": "since must be an RFC3339 timestamp",
+			})
+		}
+	}
+	if until := c.QueryParam("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]any{
+				"error": "until must be an RFC3339 timestamp",
+			})
+		}
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]any{
+				"error": "limit must be a positive integer",
+			})
+		}
+	}
+
+	list, err := deps.Jobs.List(filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, list)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/engine"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/server/auth"
 )
 
 func init() {
@@ -30,20 +31,40 @@ var wsUpgrade = websocket.Upgrader{
 }
 
 func liveStream(c echo.Context) error {
+	principal := auth.FromContext(c)
+	release, err := principal.AcquireSession()
+	if err != nil {
+		return forbidden(c, err)
+	}
+	defer release()
+	ctx, cancel := principal.WithRuntime(c.Request().Context())
+	defer cancel()
+
 	sendChan := make(chan map[string]any)
 	recvChan := handleWebSocket(c, sendChan)
 
 	cfgMap := <-recvChan
 
 	var cfg config.ExecutionConfig
-	err := mapstructure.Decode(cfgMap, &cfg)
+	err = mapstructure.Decode(cfgMap, &cfg)
 	if err != nil {
 		slog.Error("failed to read config from body", log.ErrVal(err))
 
 		return c.String(http.StatusBadRequest, "cannot unmarshal the given json body")
 	}
+	if err := checkHooks(cfg.Pipeline.Notify); err != nil {
+		sendChan <- errorBody(err)
+		close(sendChan)
+		return nil
+	}
+	if err := checkSteps(c, cfg.Pipeline.Steps); err != nil {
+		sendChan <- errorBody(err)
+		close(sendChan)
+		return nil
+	}
 	pipe := make(chan []config.Step)
-	resultChan, err := engine.ExecuteStream(c.Request().Context(), cfg, pipe)
+	// Nothing could retrieve the artifacts of a live stream once it ends
+	resultChan, err := engine.ExecuteStream(ctx, cfg, pipe, withOptions(requestOptions(c), engine.WithArtifactsDir(""))...)
 	if err != nil {
 		slog.Error(
 			"failed to spawn an engine using config",
@@ -66,6 +87,8 @@ func liveStream(c echo.Context) error {
 			sendChan <- map[string]any{
 				"error": err.Error(),
 			}
+		} else if err = checkSteps(c, []config.Step{cfg}); err != nil {
+			sendChan <- errorBody(err)
 		} else {
 			pipe <- []config.Step{cfg}
 		}
@@ -0,0 +1,26 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fmotalleb/scrapper-go/metrics"
+)
+
+func init() {
+	registerEndpoint(
+		endpoint{
+			method:  "GET",
+			path:    "/metrics",
+			handler: metricsGet,
+		},
+	)
+}
+
+// metricsGet exposes the metrics of the server in the Prometheus text format
+func metricsGet(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, metrics.ContentType)
+	c.Response().WriteHeader(http.StatusOK)
+	return metrics.Write(c.Response())
+}
@@ -1,7 +1,9 @@
 package endpoints
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 
@@ -11,6 +13,10 @@ import (
 	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/engine"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/notify"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/server/auth"
+	"github.com/fmotalleb/scrapper-go/trace"
 )
 
 func init() {
@@ -37,10 +43,44 @@ func processPipeline(c echo.Context) error {
 		slog.Error("failed to read config from body", log.ErrVal(err))
 		return c.String(http.StatusBadRequest, "cannot unmarshal the given json body")
 	}
-	res, err := engine.ExecuteConfig(c.Request().Context(), cfg)
+	principal := auth.FromContext(c)
+	if err := checkHooks(cfg.Pipeline.Notify); err != nil {
+		return badRequest(c, err)
+	}
+	if err := checkSteps(c, cfg.Pipeline.Steps); err != nil {
+		return forbidden(c, err)
+	}
+	ctx, cancel := principal.WithRuntime(c.Request().Context())
+	defer cancel()
+	opts, tr := traceOptions(c)
+	// Nothing could retrieve the artifacts of a stateless request
+	opts = withOptions(opts, engine.WithArtifactsDir(""))
+	res, err := engine.ExecuteConfig(ctx, cfg, opts...)
+	// Hooks run in the background, they must not delay nor depend on the response
+	go func() {
+		_ = notify.Dispatch(context.Background(), cfg.Pipeline.Notify, notify.NewEvent(res, err, nil))
+	}()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.Warn("execution exceeded the max runtime of the api key", slog.Duration("max_runtime", principal.Runtime()))
+		return failed(c, "execution exceeded the max runtime of the api key", tr)
+	}
+	if _, ok := policy.AsViolation(err); ok {
+		return forbidden(c, err)
+	}
 	if err != nil {
 		slog.Error("failed to execute config", log.ErrVal(err))
-		return c.String(http.StatusBadRequest, "failed to execute config. make sure the config is compatible with service")
+		return failed(c, "failed to execute config. make sure the config is compatible with service", tr)
+	}
+	if tr != nil {
+		return c.JSON(http.StatusOK, withTrace(map[string]any{"result": res}, tr))
 	}
 	return c.JSON(http.StatusOK, res)
 }
+
+// failed answers a failed execution, as JSON along with its trace when it was traced
+func failed(c echo.Context, message string, tr *trace.Node) error {
+	if tr == nil {
+		return c.String(http.StatusBadRequest, message)
+	}
+	return c.JSON(http.StatusBadRequest, withTrace(map[string]any{"error": message}, tr))
+}
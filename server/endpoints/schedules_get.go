@@ -0,0 +1,50 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fmotalleb/scrapper-go/schedule"
+)
+
+func init() {
+	registerEndpoint(
+		endpoint{
+			method:  "GET",
+			path:    "/schedules",
+			handler: schedulesList,
+		},
+	)
+	registerEndpoint(
+		endpoint{
+			method:  "GET",
+			path:    "/schedules/:name",
+			handler: schedulesGet,
+		},
+	)
+}
+
+func schedulesList(c echo.Context) error {
+	if deps.Schedules == nil {
+		return c.JSON(http.StatusOK, []schedule.Info{})
+	}
+	return c.JSON(http.StatusOK, deps.Schedules.List())
+}
+
+func schedulesGet(c echo.Context) error {
+	name := c.Param("name")
+	if deps.Schedules == nil {
+		return c.JSON(http.StatusNotFound, map[string]any{
+			"name": name,
+		})
+	}
+	info, err := deps.Schedules.Get(name)
+	if errors.Is(err, schedule.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]any{
+			"name": name,
+		})
+	}
+	return c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,40 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func init() {
+	registerEndpoint(
+		endpoint{
+			method:  "GET",
+			path:    "/sessions/:id/artifacts",
+			handler: sessionArtifactsManifest,
+		},
+	)
+	registerEndpoint(
+		endpoint{
+			method:  "GET",
+			path:    "/sessions/:id/artifacts/*",
+			handler: sessionArtifactFile,
+		},
+	)
+}
+
+func sessionArtifactsManifest(c echo.Context) error {
+	id := c.Param("id")
+	if _, ok := getSession(c, id); !ok {
+		return c.JSON(http.StatusNotFound, map[string]any{"id": id})
+	}
+	return serveManifest(c, id, sessionArtifactsDir(id))
+}
+
+func sessionArtifactFile(c echo.Context) error {
+	id := c.Param("id")
+	if _, ok := getSession(c, id); !ok {
+		return c.JSON(http.StatusNotFound, map[string]any{"id": id})
+	}
+	return serveArtifact(c, id, sessionArtifactsDir(id))
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/server/auth"
 	"github.com/fmotalleb/scrapper-go/session"
 )
 
@@ -45,13 +47,34 @@ func sessionsCreate(c echo.Context) error {
 			"error": "Invalid configuration structure: " + err.Error(),
 		})
 	}
-	res, err := session.NewSession(cfg, timeout)
+	principal := auth.FromContext(c)
+	if err := checkHooks(cfg.Pipeline.Notify); err != nil {
+		return badRequest(c, err)
+	}
+	if err := checkSteps(c, cfg.Pipeline.Steps); err != nil {
+		return forbidden(c, err)
+	}
+	release, err := principal.AcquireSession()
+	if err != nil {
+		return forbidden(c, err)
+	}
+	maxRuntime := principal.Runtime()
+	if maxRuntime > 0 && timeout > maxRuntime {
+		timeout = maxRuntime
+	}
+	// Recordings are only written once the session ends, along with the deletion of its artifacts
+	cfg.Pipeline.Trace, cfg.Pipeline.Video = config.TraceRecording{}, config.VideoRecording{}
+	artifactsDir := newSessionArtifacts()
+	res, err := session.NewSession(cfg, timeout, principal.Owner(), withOptions(requestOptions(c), engine.WithArtifactsDir(artifactsDir))...)
 	if err != nil {
+		release()
 		slog.Error("failed to create session", log.ErrVal(err))
 		return c.JSON(http.StatusBadRequest, map[string]any{
 			"error": "Failed to create session: " + err.Error(),
 		})
 	}
+	sessionArtifacts.Store(res.ID, artifactsDir)
+	go watchSession(res, maxRuntime, release)
 	slog.Info("session created successfully", slog.String("id", res.ID), slog.Duration("timeout", timeout))
 	return c.JSON(
 		http.StatusOK,
@@ -61,3 +84,22 @@ func sessionsCreate(c echo.Context) error {
 		},
 	)
 }
+
+// watchSession kills the session once it reaches the max runtime of its api key,
+// it releases its session slot and deletes its artifacts when it ends
+func watchSession(sess *session.Session, maxRuntime time.Duration, release func()) {
+	defer release()
+	defer dropSessionArtifacts(sess.ID)
+	var expired <-chan time.Time
+	if maxRuntime > 0 {
+		timer := time.NewTimer(maxRuntime)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-sess.Done():
+	case <-expired:
+		slog.Info("session reached the max runtime of its api key", slog.String("id", sess.ID), slog.Duration("max_runtime", maxRuntime))
+		sess.Kill()
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/fmotalleb/scrapper-go/server/auth"
 	"github.com/fmotalleb/scrapper-go/session"
 )
 
@@ -18,9 +19,19 @@ func init() {
 	)
 }
 
+// sessionsGet lists the sessions of the api key
 func sessionsGet(c echo.Context) error {
 	return c.JSON(
 		http.StatusOK,
-		session.GetSessions(),
+		session.GetSessions(auth.FromContext(c).Owner()),
 	)
 }
+
+// getSession finds a session created with the api key of the request, the sessions of other keys are not found
+func getSession(c echo.Context, id string) (*session.Session, bool) {
+	sess, ok := session.GetSession(id)
+	if !ok || !auth.FromContext(c).Owns(sess.Owner) {
+		return nil, false
+	}
+	return sess, true
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/log"
-	"github.com/fmotalleb/scrapper-go/session"
 )
 
 func init() {
@@ -26,7 +25,7 @@ func init() {
 func sessionHandle(c echo.Context) error {
 	id := c.Param("id")
 	slog.Info("session handle requested", slog.String("id", id))
-	sess, ok := session.GetSession(id)
+	sess, ok := getSession(c, id)
 	if !ok {
 		return c.JSON(http.StatusNotFound, map[string]any{"id": id})
 	}
@@ -44,7 +43,10 @@ func sessionHandle(c echo.Context) error {
 			slog.Error("failed to decode config", log.ErrVal(err))
 			return c.String(http.StatusBadRequest, "invalid config format")
 		}
-		res, err := sess.Handle(cfg)
+		if err := checkSteps(c, []config.Step{cfg}); err != nil {
+			return forbidden(c, err)
+		}
+		res, err := sess.Handle(c.Request().Context(), cfg)
 		if err != nil {
 			slog.Error("failed to execute config", log.ErrVal(err))
 			return c.String(http.StatusBadRequest, "failed to execute config")
@@ -58,7 +60,10 @@ func sessionHandle(c echo.Context) error {
 			slog.Error("failed to decode config array", log.ErrVal(err))
 			return c.String(http.StatusBadRequest, "invalid config array format")
 		}
-		res, err := sess.Handle(steps...)
+		if err := checkSteps(c, steps); err != nil {
+			return forbidden(c, err)
+		}
+		res, err := sess.Handle(c.Request().Context(), steps...)
 		if err != nil {
 			slog.Error("failed to execute config steps", log.ErrVal(err))
 			return c.String(http.StatusBadRequest, "failed to execute config steps")
@@ -5,8 +5,6 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
-
-	"github.com/fmotalleb/scrapper-go/session"
 )
 
 func init() {
@@ -23,8 +21,8 @@ func sessionKill(c echo.Context) error {
 	id := c.Param("id")
 	slog.Info("session kill requested", slog.String("id", id))
 
-	if session, ok := session.GetSession(id); ok {
-		session.Kill()
+	if sess, ok := getSession(c, id); ok {
+		sess.Kill()
 		return c.JSON(http.StatusOK, map[string]any{
 			"id": id,
 		})
@@ -3,19 +3,152 @@ package server
 
 import (
 	"log/slog"
+	"path/filepath"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine"
+	"github.com/fmotalleb/scrapper-go/jobs"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/metrics"
+	"github.com/fmotalleb/scrapper-go/policy"
+	"github.com/fmotalleb/scrapper-go/pool"
+	"github.com/fmotalleb/scrapper-go/schedule"
+	"github.com/fmotalleb/scrapper-go/server/auth"
 	"github.com/fmotalleb/scrapper-go/server/endpoints"
 )
 
-func StartServer(address string) error {
+// Config of the api server
+type Config struct {
+	Address string
+	// Pool of browsers shared by `/process` requests and jobs, disabled when PoolEnabled is false
+	PoolEnabled bool
+	Pool        pool.Config
+	// JobsDir persists jobs as files in this directory, jobs are kept in memory when empty
+	JobsDir       string
+	JobsRetention time.Duration
+	// SchedulesDir holds pipeline files with a `schedule` field, scheduling is disabled when empty
+	SchedulesDir string
+	// KeysFile holds the api keys and their limits, authentication is disabled when empty
+	KeysFile string
+	// PolicyFile restricts what pipelines may do, every pipeline of the server is subject to it
+	PolicyFile string
+	// LimitsDefault applies to pipelines that do not set their own limits, LimitsCap bounds the ones they set
+	LimitsDefault config.Limits
+	LimitsCap     config.Limits
+	// ArtifactsDir keeps the failure artifacts of jobs and sessions, artifacts of API pipelines are disabled when empty
+	ArtifactsDir string
+	// AllowWebhooks accepts webhook hooks in pipelines sent by clients, they are rejected otherwise
+	AllowWebhooks bool
+}
+
+func StartServer(cfg Config) error {
 	e := echo.New()
-	endpoints.PopulateEndpoints(e)
-	if err := e.Start(address); err != nil {
+	var authenticator *auth.Authenticator
+	if cfg.KeysFile != "" {
+		var err error
+		if authenticator, err = auth.Load(cfg.KeysFile); err != nil {
+			slog.Error("failed to load api keys", log.ErrVal(err))
+			return err
+		}
+	} else {
+		slog.Warn("api authentication is disabled, keep the server behind a reverse proxy")
+	}
+	if cfg.PolicyFile != "" {
+		p, err := policy.Load(cfg.PolicyFile)
+		if err != nil {
+			slog.Error("failed to load policy", log.ErrVal(err))
+			return err
+		}
+		policy.Set(p)
+		defer policy.Set(nil)
+	}
+	opts := []engine.Option{engine.WithLimits(cfg.LimitsDefault, cfg.LimitsCap)}
+	if cfg.PoolEnabled {
+		browsers := pool.New(cfg.Pool)
+		defer browsers.Close()
+		registerPoolMetrics(browsers)
+		opts = append(opts, engine.WithBrowserProvider(browsers))
+	}
+
+	manager, err := newJobManager(cfg, opts)
+	if err != nil {
+		slog.Error("failed to create job manager", log.ErrVal(err))
+		return err
+	}
+	defer manager.Close()
+
+	scheduler, err := startScheduler(cfg.SchedulesDir, opts)
+	if err != nil {
+		slog.Error("failed to load schedules", log.ErrVal(err))
+		return err
+	}
+	if scheduler != nil {
+		defer scheduler.Stop()
+	}
+
+	endpoints.PopulateEndpoints(e, endpoints.Dependencies{
+		EngineOptions: opts,
+		Jobs:          manager,
+		Schedules:     scheduler,
+		Auth:          authenticator,
+		ArtifactsDir:  cfg.ArtifactsDir,
+		AllowWebhooks: cfg.AllowWebhooks,
+	})
+	if err := e.Start(cfg.Address); err != nil {
 		slog.Error("failed to start server", log.ErrVal(err))
 		return err
 	}
 	return nil
 }
+
+func newJobManager(cfg Config, opts []engine.Option) (*jobs.Manager, error) {
+	var store jobs.Store = jobs.NewMemoryStore()
+	if cfg.JobsDir != "" {
+		fileStore, err := jobs.NewFileStore(cfg.JobsDir)
+		if err != nil {
+			return nil, err
+		}
+		store = fileStore
+	}
+	var artifactsDir string
+	if cfg.ArtifactsDir != "" {
+		artifactsDir = filepath.Join(cfg.ArtifactsDir, "jobs")
+	}
+	return jobs.NewManager(jobs.Config{
+		Store:         store,
+		Retention:     cfg.JobsRetention,
+		EngineOptions: opts,
+		ArtifactsDir:  artifactsDir,
+	})
+}
+
+func startScheduler(dir string, opts []engine.Option) (*schedule.Scheduler, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	pipelines, err := schedule.LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	scheduler, err := schedule.New(pipelines, opts...)
+	if err != nil {
+		return nil, err
+	}
+	scheduler.Start()
+	return scheduler, nil
+}
+
+func registerPoolMetrics(browsers *pool.Pool) {
+	metrics.RegisterGaugeFunc("scrapper_pool_capacity", "Maximum concurrent executions of the browser pool, zero means no limit.", func() float64 {
+		return float64(browsers.Stats().Capacity)
+	})
+	metrics.RegisterGaugeFunc("scrapper_pool_in_use", "Executions holding a pooled browser.", func() float64 {
+		return float64(browsers.Stats().InUse)
+	})
+	metrics.RegisterGaugeFunc("scrapper_pool_browsers", "Browsers kept warm by the pool.", func() float64 {
+		return float64(browsers.Stats().Browsers)
+	})
+}
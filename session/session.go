@@ -12,15 +12,18 @@ import (
 	"github.com/fmotalleb/scrapper-go/config"
 	"github.com/fmotalleb/scrapper-go/engine"
 	"github.com/fmotalleb/scrapper-go/log"
+	"github.com/fmotalleb/scrapper-go/metrics"
 	"github.com/fmotalleb/scrapper-go/utils"
 )
 
 type Session struct {
-	ID             string
+	ID string
+	// Owner is the name of the api key that created the session, empty without authentication
+	Owner          string
 	lock           sync.Locker
 	ctx            context.Context
 	cancel         func()
-	sendChannel    chan<- []config.Step
+	sendChannel    chan<- engine.Batch
 	receiveChannel <-chan map[string]any
 	killTimer      *time.Timer
 	timeout        time.Duration
@@ -33,11 +36,34 @@ type SessionStore struct {
 
 var store = &SessionStore{sessions: make(map[string]*Session)}
 
-func NewSession(cfg config.ExecutionConfig, timeout time.Duration) (*Session, error) {
+func init() {
+	metrics.RegisterGaugeFunc("scrapper_active_sessions", "Open API sessions.", func() float64 {
+		return float64(store.count())
+	})
+}
+
+func (s *SessionStore) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.sessions)
+}
+
+// Stream runs the batches received from in until ctx is done, sending one result per batch
+type Stream func(ctx context.Context, in <-chan engine.Batch) (<-chan map[string]any, error)
+
+// NewSession starts a stream of the pipeline that is killed once idle for timeout, opts are passed to the engine
+func NewSession(cfg config.ExecutionConfig, timeout time.Duration, owner string, opts ...engine.Option) (*Session, error) {
+	return Open(func(ctx context.Context, in <-chan engine.Batch) (<-chan map[string]any, error) {
+		return engine.ExecuteBatches(ctx, cfg, in, opts...)
+	}, timeout, owner)
+}
+
+// Open starts the stream in a new session that is killed once idle for timeout
+func Open(stream Stream, timeout time.Duration, owner string) (*Session, error) {
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
-	sendChannel := make(chan []config.Step)
-	receiveChannel, err := engine.ExecuteStream(ctx, cfg, sendChannel)
+	sendChannel := make(chan engine.Batch)
+	receiveChannel, err := stream(ctx, sendChannel)
 	if err != nil {
 		cancel()
 		slog.Error("Failed to execute stream", log.ErrVal(err))
@@ -46,6 +72,7 @@ func NewSession(cfg config.ExecutionConfig, timeout time.Duration) (*Session, er
 	timer := time.NewTimer(timeout)
 	session := &Session{
 		ID:             uuid.New().String(),
+		Owner:          owner,
 		lock:           new(sync.Mutex),
 		ctx:            ctx,
 		sendChannel:    sendChannel,
@@ -64,14 +91,15 @@ func NewSession(cfg config.ExecutionConfig, timeout time.Duration) (*Session, er
 	return session, nil
 }
 
-func GetSessions() []string {
+// GetSessions lists the ids of the sessions of owner, every session when owner is empty
+func GetSessions(owner string) []string {
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
-	result := make([]string, len(store.sessions))
-	index := 0
-	for id := range store.sessions {
-		result[index] = id
-		index++
+	result := make([]string, 0, len(store.sessions))
+	for id, session := range store.sessions {
+		if owner == "" || session.Owner == owner {
+			result = append(result, id)
+		}
 	}
 	return result
 }
@@ -88,14 +116,15 @@ func GetSession(id string) (*Session, bool) {
 	return session, exists
 }
 
-func (s *Session) Handle(steps ...config.Step) (*map[string]any, error) {
+// Handle runs the steps in the session, ctx carries the span of the request
+func (s *Session) Handle(ctx context.Context, steps ...config.Step) (*map[string]any, error) {
 	s.resetTimer()
 	s.lock.Lock()
 	defer func() {
 		s.resetTimer()
 		s.lock.Unlock()
 	}()
-	s.sendChannel <- steps
+	s.sendChannel <- engine.Batch{Ctx: ctx, Steps: steps}
 
 	slog.Debug("Steps sent to session", slog.String("session_id", s.ID), slog.Any("steps", steps))
 	result, err := utils.WithDeadline(s.receiveChannel, s.timeout)
@@ -150,12 +179,18 @@ func (s *Session) killerDaemon() {
 				slog.String("session_id", s.ID),
 				slog.Duration("timeout", s.timeout),
 			)
+			metrics.Timeouts.Inc(metrics.TimeoutSession)
 			s.cancel()
 			return
 		}
 	}
 }
 
+// Done is closed once the session is killed or timed out
+func (s *Session) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
 func (s *Session) Kill() {
 	s.cancel()
 	slog.Info("Session manually killed", slog.String("session_id", s.ID))
@@ -0,0 +1,78 @@
+package session
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fmotalleb/scrapper-go/config"
+	"github.com/fmotalleb/scrapper-go/engine"
+)
+
+// countingStream answers every batch with the number of its steps, until the session ends
+func countingStream(ctx context.Context, in <-chan engine.Batch) (<-chan map[string]any, error) {
+	out := make(chan map[string]any)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case batch := <-in:
+				out <- map[string]any{"steps": len(batch.Steps)}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func TestSessionsOfOwner(t *testing.T) {
+	alice, err := Open(countingStream, time.Minute, "alice")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer alice.Kill()
+	bob, err := Open(countingStream, time.Minute, "bob")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer bob.Kill()
+
+	if ids := GetSessions("alice"); !slices.Equal(ids, []string{alice.ID}) {
+		t.Errorf("sessions of alice = %v, expected %v", ids, []string{alice.ID})
+	}
+	if ids := GetSessions(""); !slices.Contains(ids, alice.ID) || !slices.Contains(ids, bob.ID) {
+		t.Errorf("every session = %v, expected both sessions", ids)
+	}
+	res, err := alice.Handle(context.Background(), config.Step{"nop": "a"}, config.Step{"nop": "b"})
+	if err != nil || (*res)["steps"] != 2 {
+		t.Errorf("Handle = %v, %v, expected the result of the batch", res, err)
+	}
+
+	bob.Kill()
+	<-bob.Done()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, ok := GetSession(bob.ID); !ok {
+			break
+		}
+	}
+	if _, ok := GetSession(bob.ID); ok {
+		t.Error("killed session is still listed")
+	}
+	if _, ok := GetSession(alice.ID); !ok {
+		t.Error("killing a session removed another one")
+	}
+}
+
+func TestSessionTimeout(t *testing.T) {
+	sess, err := Open(countingStream, 20*time.Millisecond, "alice")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	select {
+	case <-sess.Done():
+	case <-time.After(5 * time.Second):
+		sess.Kill()
+		t.Fatal("idle session was not killed")
+	}
+}
@@ -0,0 +1,92 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Masked replaces the values of secret keys in span attributes
+const Masked = "***"
+
+const maxAttributeLength = 512
+
+// secretKeys are parts of config keys holding secrets (e.g. `password`, `X-Api-Key`), compared case-insensitively
+var secretKeys = []string{"password", "passwd", "secret", "token", "auth", "cookie", "credential", "apikey", "api-key", "api_key", "private"}
+
+// maskedStepValues are keys whose values are masked for some step types, text typed into pages is often a secret
+var maskedStepValues = map[string][]string{
+	"fill": {"value"},
+}
+
+// StepAttributes describes the config of a step as `scrapper.step.config.<key>` attributes, secrets are masked.
+// skip lists keys left out, e.g. nested steps that have their own spans
+func StepAttributes(stepType string, conf map[string]any, skip ...string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("scrapper.step.type", stepType)}
+	for key, value := range conf {
+		if slices.Contains(skip, key) {
+			continue
+		}
+		var masked any
+		if slices.Contains(maskedStepValues[stepType], key) {
+			masked = Masked
+		} else {
+			masked = Mask(key, value)
+		}
+		attrs = append(attrs, attribute.String("scrapper.step.config."+key, attributeValue(masked)))
+	}
+	return attrs
+}
+
+// Mask replaces the value of a secret key, nested maps and lists are masked by their own keys
+func Mask(key string, value any) any {
+	if IsSecretKey(key) {
+		return Masked
+	}
+	switch val := value.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(val))
+		for k, v := range val {
+			masked[k] = Mask(k, v)
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(val))
+		for i, v := range val {
+			masked[i] = Mask("", v)
+		}
+		return masked
+	}
+	return value
+}
+
+// IsSecretKey reports whether values of the key are secrets
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func attributeValue(value any) string {
+	var text string
+	switch val := value.(type) {
+	case string:
+		text = val
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return "<unencodable>"
+		}
+		text = string(data)
+	}
+	if len(text) > maxAttributeLength {
+		return strings.ToValidUTF8(text[:maxAttributeLength], "") + "..."
+	}
+	return text
+}
@@ -0,0 +1,156 @@
+// Package telemetry exports OpenTelemetry spans of pipeline runs, steps and loop iterations
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+const (
+	instrumentation   = "github.com/fmotalleb/scrapper-go"
+	serviceName       = "scrapper-go"
+	defaultTracesPath = "/v1/traces"
+)
+
+// Config of the span exporter, spans are not recorded when Exporter is empty
+type Config struct {
+	// Exporter is otlp, stdout or file
+	Exporter string
+	// Endpoint of the OTLP HTTP collector, e.g. http://localhost:4318 (/v1/traces is added when the path is empty).
+	// The standard OTEL_EXPORTER_OTLP_* variables apply when it is empty
+	Endpoint string
+	// File receives the spans of the file exporter, one JSON object per span
+	File string
+}
+
+var enabled atomic.Bool
+
+// Setup installs the global tracer provider and the W3C trace context propagator,
+// shutdown flushes the pending spans
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, closer, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	enabled.Store(true)
+	slog.Info("opentelemetry spans enabled", slog.String("exporter", cfg.Exporter))
+	return func(ctx context.Context) error {
+		enabled.Store(false)
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			endpoint, err := tracesURL(cfg.Endpoint)
+			if err != nil {
+				return nil, nil, err
+			}
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		return exporter, nil, err
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+		return exporter, nil, err
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, nil, errors.New("the file exporter requires a file")
+		}
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open spans file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			_ = file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported span exporter %q, expected otlp, stdout or file", cfg.Exporter)
+}
+
+// tracesURL adds the default traces path to collector urls without one
+func tracesURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid otlp endpoint %q, expected a url like http://localhost:4318", endpoint)
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = defaultTracesPath
+	}
+	return u.String(), nil
+}
+
+// Enabled reports whether spans are exported, callers skip building spans otherwise
+func Enabled() bool {
+	return enabled.Load()
+}
+
+func Tracer() oteltrace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Extract returns ctx with the trace context received in the headers of a request
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Detach returns ctx carrying the span of parent, without the cancellation of parent.
+// Used by work outliving the request that started it, e.g. jobs
+func Detach(ctx, parent context.Context) context.Context {
+	if parent == nil {
+		return ctx
+	}
+	return oteltrace.ContextWithSpanContext(ctx, oteltrace.SpanContextFromContext(parent))
+}
+
+// End records the error of the span, if any, and ends it
+func End(span oteltrace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
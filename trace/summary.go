@@ -0,0 +1,92 @@
+package trace
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const maxSummaryValue = 80
+
+var outcomeMarks = map[string]string{
+	OutcomeSuccess: "ok",
+	OutcomeFailure: "FAIL",
+	OutcomeRunning: "...",
+}
+
+// Summary renders the trace as an indented tree, one line per node followed by its error and templates:
+//
+//	pipeline failure in 6.215s: 2 steps, 1 failed
+//	ok        1.204s  goto: https://example.com
+//	FAIL       5.01s  click: #missing
+//	                    error: timeout 5000ms exceeded
+func (n *Node) Summary() string {
+	var sb strings.Builder
+	steps, failed := n.count()
+	fmt.Fprintf(&sb, "%s %s in %s: %d steps, %d failed\n", n.Type, n.Outcome, formatDuration(n), steps, failed)
+	for _, child := range n.Children {
+		child.write(&sb, 0)
+	}
+	return sb.String()
+}
+
+func (n *Node) write(sb *strings.Builder, depth int) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(sb, "%-4s %10s  %s%s\n", outcomeMarks[n.Outcome], formatDuration(n), indent, n.label())
+	detail := strings.Repeat(" ", 17) + indent + "  "
+	if n.Error != "" {
+		fmt.Fprintf(sb, "%serror: %s\n", detail, shorten(n.Error))
+	}
+	for _, tmpl := range n.Templates {
+		fmt.Fprintf(sb, "%s%s => %s\n", detail, shorten(tmpl.Source), shorten(tmpl.Result))
+	}
+	for _, child := range n.Children {
+		child.write(sb, depth+1)
+	}
+}
+
+func (n *Node) label() string {
+	switch {
+	case n.Iteration != nil:
+		return fmt.Sprintf("#%d", *n.Iteration)
+	case n.Step != "":
+		return n.Step
+	}
+	return n.Type
+}
+
+// count returns the number of steps under the node and how many of them failed, iterations are not steps
+func (n *Node) count() (steps, failed int) {
+	for _, child := range n.Children {
+		if child.Iteration == nil {
+			steps++
+			if child.Outcome == OutcomeFailure {
+				failed++
+			}
+		}
+		s, f := child.count()
+		steps += s
+		failed += f
+	}
+	return steps, failed
+}
+
+func formatDuration(n *Node) string {
+	if n.Outcome == OutcomeRunning {
+		return "-"
+	}
+	d := time.Duration(n.DurationMS * float64(time.Millisecond))
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// shorten keeps values on a single line of reasonable length
+func shorten(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if runes := []rune(value); len(runes) > maxSummaryValue {
+		return string(runes[:maxSummaryValue-3]) + "..."
+	}
+	return value
+}
@@ -0,0 +1,86 @@
+// Package trace records the tree of step executions of a run, with their timings, evaluated templates and errors
+package trace
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeRunning marks nodes that never finished, e.g. iterations abandoned by a parallel loop
+	OutcomeRunning = "running"
+)
+
+// Node is a step, a loop iteration or the whole pipeline (the root returned by New)
+type Node struct {
+	Type string `json:"type"`
+	// Step summarizes the step, e.g. `click: #submit`
+	Step       string     `json:"step,omitempty"`
+	Iteration  *int       `json:"iteration,omitempty"`
+	Start      time.Time  `json:"start"`
+	End        time.Time  `json:"end"`
+	DurationMS float64    `json:"duration_ms"`
+	Outcome    string     `json:"outcome"`
+	Error      string     `json:"error,omitempty"`
+	Templates  []Template `json:"templates,omitempty"`
+	Children   []*Node    `json:"children,omitempty"`
+
+	lock sync.Mutex
+}
+
+// Template is a template evaluated by a step and its result
+type Template struct {
+	Source string `json:"source"`
+	Result string `json:"result"`
+}
+
+// New starts the trace of a run, its children are the top-level steps
+func New() *Node {
+	return &Node{
+		Type:    "pipeline",
+		Start:   time.Now(),
+		Outcome: OutcomeRunning,
+	}
+}
+
+// StartStep adds a running step under the node
+func (n *Node) StartStep(stepType, step string) *Node {
+	return n.add(&Node{Type: stepType, Step: step})
+}
+
+// StartIteration adds a running loop iteration under the node
+func (n *Node) StartIteration(index int) *Node {
+	return n.add(&Node{Type: "iteration", Iteration: &index})
+}
+
+func (n *Node) add(child *Node) *Node {
+	child.Start = time.Now()
+	child.Outcome = OutcomeRunning
+	// Iterations of parallel loops add their children concurrently
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// Finish records the end of the node and its error, if any
+func (n *Node) Finish(err error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.End = time.Now()
+	n.DurationMS = float64(n.End.Sub(n.Start).Microseconds()) / 1000
+	n.Outcome = OutcomeSuccess
+	if err != nil {
+		n.Outcome = OutcomeFailure
+		n.Error = err.Error()
+	}
+}
+
+// RecordTemplate implements utils.TemplateRecorder.
+func (n *Node) RecordTemplate(source, result string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.Templates = append(n.Templates, Template{Source: source, Result: result})
+}
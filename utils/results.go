@@ -0,0 +1,49 @@
+package utils
+
+import "strings"
+
+const resultMetaPrefix = "__$"
+
+// ResultMetaKey returns the key used to keep bookkeeping data of a result key
+func ResultMetaKey(key string) string {
+	return resultMetaPrefix + key
+}
+
+// IsResultMetaKey reports whether the key holds bookkeeping data of another key
+func IsResultMetaKey(key string) bool {
+	return strings.HasPrefix(key, resultMetaPrefix)
+}
+
+// IsPublicResultKey reports whether the key belongs in the final output, meta keys are left out
+func IsPublicResultKey(key string) bool {
+	return !IsResultMetaKey(key)
+}
+
+// PublicResults copies every public key of the result map
+func PublicResults(r map[string]any) map[string]any {
+	out := make(map[string]any, len(r)/2)
+	for k, v := range r {
+		if IsPublicResultKey(k) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// OutputMetaKey holds the metadata of a run in its output, e.g. the paths of its recordings.
+// It is not a result of the steps, so templates and monitor mode ignore it
+const OutputMetaKey = "$meta"
+
+// WithoutOutputMeta returns the output without its metadata
+func WithoutOutputMeta(output map[string]any) map[string]any {
+	if _, ok := output[OutputMetaKey]; !ok {
+		return output
+	}
+	out := make(map[string]any, len(output))
+	for k, v := range output {
+		if k != OutputMetaKey {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPublicResults(t *testing.T) {
+	results := map[string]any{
+		"title":                "shop",
+		"_csrf":                "token",
+		"__private":            1,
+		ResultMetaKey("title"): true,
+	}
+	want := map[string]any{"title": "shop", "_csrf": "token", "__private": 1}
+	if got := PublicResults(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("PublicResults = %v, expected %v", got, want)
+	}
+}
@@ -2,13 +2,54 @@ package utils
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
 	"text/template"
+	"unicode"
 
 	"github.com/mxschmitt/playwright-go"
+
+	"github.com/fmotalleb/scrapper-go/policy"
 )
 
+// singleActionTemplate matches templates made of exactly one action, e.g. `{{ .rows }}`
+var singleActionTemplate = regexp.MustCompile(`(?s)^\s*\{\{-?\s*(.*?)\s*-?\}\}\s*$`)
+
+const captureFunc = "__capture"
+
+// disabledFunctionsVar holds the template functions disabled for a run, see DisableFunctions
+const disabledFunctionsVar = HiddenVarPrefix + "disabled_functions"
+
+// ErrFunctionDisabled is returned by template functions disabled for the run
+var ErrFunctionDisabled = errors.New("template function is disabled for this execution")
+
+// DisableFunctions disables template functions for the templates rendered with v, on top of the ones
+// disabled by the policy. `page` removes the `.page` object, like in the policy
+func DisableFunctions(v Vars, names []string) {
+	v.SetOnce(disabledFunctionsVar, names)
+}
+
+// checkFunction rejects the template functions disabled by the policy or for the run
+func checkFunction(vars Vars, name string) error {
+	if err := policy.Current().CheckFunction(name); err != nil {
+		return err
+	}
+	if names, _ := vars.GetOr(disabledFunctionsVar, nil).([]string); slices.Contains(names, name) {
+		return fmt.Errorf("%w: %s", ErrFunctionDisabled, name)
+	}
+	return nil
+}
+
+// namedPage is a page that knows the name of its active tab, exposed as `{{ .tab }}`
+type namedPage interface {
+	ActiveTab() string
+}
+
 func unShadow(data map[string]any, key string) map[string]any {
 	if _, exists := data[key]; exists {
 		newKey := fmt.Sprintf("_%s", key)
@@ -28,7 +69,49 @@ func TemplateEvalMapper(vars Vars, page playwright.Page) func(string) (string, e
 	}
 }
 
+// EvaluateTemplate renders the text using variables both as functions (`{{ name }}`)
+// and as data (`{{ .name }}`, `{{ .results.key }}`, `{{ index .rows 0 }}`)
 func EvaluateTemplate(text string, vars Vars, page playwright.Page) (string, error) {
+	output, err := evaluateTemplate(text, vars, page, nil)
+	if err == nil {
+		recordTemplate(vars, text, output)
+	}
+	return output, err
+}
+
+// EvaluateTemplateValue evaluates a template made of a single action (e.g. `{{ .rows }}`)
+// keeping the native type of its result (lists, maps, numbers, ...).
+// Any other template is rendered as a string.
+func EvaluateTemplateValue(text string, vars Vars, page playwright.Page) (any, error) {
+	match := singleActionTemplate.FindStringSubmatch(text)
+	if match == nil || match[1] == "" {
+		return EvaluateTemplate(text, vars, page)
+	}
+	var value any
+	capture := template.FuncMap{
+		captureFunc: func(v any) string {
+			value = v
+			return ""
+		},
+	}
+	wrapped := fmt.Sprintf("{{ %s (%s) }}", captureFunc, match[1])
+	if _, err := evaluateTemplate(wrapped, vars, page, capture); err != nil {
+		// Actions that cannot be wrapped (variable declarations, comments, ...) are rendered as-is
+		slog.Debug("template cannot be evaluated as a value, rendering it as string", slog.String("template", text), slog.Any("err", err))
+		return EvaluateTemplate(text, vars, page)
+	}
+	recordTemplate(vars, text, ToString(value))
+	return value, nil
+}
+
+// recordTemplate notifies the recorder of the variables, plain texts are not templates worth recording
+func recordTemplate(vars Vars, text, output string) {
+	if recorder, ok := vars.GetOr(TemplateRecorderVar, nil).(TemplateRecorder); ok && strings.Contains(text, "{{") {
+		recorder.RecordTemplate(text, output)
+	}
+}
+
+func evaluateTemplate(text string, vars Vars, page playwright.Page, extra template.FuncMap) (string, error) {
 	templateObj := template.New("template")
 
 	variables := vars.LiveSnapshot()
@@ -36,19 +119,82 @@ func EvaluateTemplate(text string, vars Vars, page playwright.Page) (string, err
 		slog.Error("found a page variable in live snapshot generated for template, renaming old page variable to _page")
 		variables = unShadow(variables, "eval")
 	}
-	variables["eval"] = page.Evaluate
-	templateObj = templateObj.Funcs(variables)
+	// Templates may be rendered outside of a browser (e.g. notifications), eval is only available with a page
+	if page != nil {
+		variables["eval"] = page.Evaluate
+	}
+	templateObj = templateObj.Funcs(helperFuncs).Funcs(templateFuncs(variables)).Funcs(disabledFuncs(vars)).Funcs(extra)
 
 	templateObj, err := templateObj.Parse(text)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %s", err)
 	}
 
-	variables["page"] = page
+	data := vars.Snapshot()
+	if checkFunction(vars, policy.FunctionPage) == nil {
+		data["page"] = page
+	}
+	if named, ok := page.(namedPage); ok {
+		data["tab"] = named.ActiveTab()
+	}
 	output := bytes.NewBufferString("")
-	err = templateObj.Execute(output, variables)
+	err = templateObj.Execute(output, data)
 	if err != nil {
-		return "", fmt.Errorf("failed to execute template using vars snapshot: %s", err)
+		return "", fmt.Errorf("failed to execute template using vars snapshot: %w", err)
 	}
 	return output.String(), nil
 }
+
+// helperFuncs are available in every template, variables with the same name shadow them
+var helperFuncs = template.FuncMap{
+	"toJSON": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"fromJSON": ParseJSON,
+	"str":      ToString,
+}
+
+// disabledFuncs replaces the functions disabled by the policy or for the run, calling them fails with a violation
+// or ErrFunctionDisabled
+func disabledFuncs(vars Vars) template.FuncMap {
+	names, _ := vars.GetOr(disabledFunctionsVar, nil).([]string)
+	funcs := make(template.FuncMap)
+	for _, name := range slices.Concat(policy.Current().DisabledFunctions(), names) {
+		if !isIdentifier(name) {
+			continue
+		}
+		err := checkFunction(vars, name)
+		funcs[name] = func(...any) (any, error) {
+			return nil, err
+		}
+	}
+	return funcs
+}
+
+// templateFuncs drops variables that cannot be used as a template function name (e.g. `my-var`),
+// they are still reachable using `{{ index . "my-var" }}`
+func templateFuncs(variables map[string]any) template.FuncMap {
+	funcs := make(template.FuncMap, len(variables))
+	for k, v := range variables {
+		if isIdentifier(k) {
+			funcs[k] = v
+		}
+	}
+	return funcs
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && unicode.IsDigit(r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDisableFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		disabled []string
+		template string
+		want     string
+		err      error
+	}{
+		{name: "eval disabled", disabled: []string{"eval", "page"}, template: `{{ eval "1 + 1" }}`, err: ErrFunctionDisabled},
+		{name: "page removed", disabled: []string{"eval", "page"}, template: `{{ if .page }}page{{ else }}none{{ end }}`, want: "none"},
+		{name: "variable function disabled", disabled: []string{"title"}, template: `{{ title }}`, err: ErrFunctionDisabled},
+		{name: "variable data still readable", disabled: []string{"title"}, template: `{{ .title }}`, want: "shop"},
+		{name: "other functions", disabled: []string{"eval"}, template: `{{ str .count }}`, want: "2"},
+		{name: "nothing disabled", template: `{{ title }}`, want: "shop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := make(Vars)
+			v.SetOnce("title", "shop")
+			v.SetOnce("count", 2)
+			if tt.disabled != nil {
+				DisableFunctions(v, tt.disabled)
+			}
+			got, err := EvaluateTemplate(tt.template, v, nil)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("EvaluateTemplate(%q) = %q, %v, expected %v", tt.template, got, err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("EvaluateTemplate(%q) = %q, %v, expected %q", tt.template, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestDisabledFunctionsOfClones checks the scopes of loops and branches keep the restrictions of the run
+func TestDisabledFunctionsOfClones(t *testing.T) {
+	v := make(Vars)
+	DisableFunctions(v, []string{"eval"})
+	if _, err := EvaluateTemplate(`{{ eval "1" }}`, v.Clone(), nil); !errors.Is(err, ErrFunctionDisabled) {
+		t.Errorf("eval in a cloned scope = %v, expected %v", err, ErrFunctionDisabled)
+	}
+}
+
+func TestCheckVarName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"title":                    true,
+		"_private":                 true,
+		"$price":                   true,
+		HiddenVarPrefix + "limits": false,
+		disabledFunctionsVar:       false,
+		HiddenVarPrefix:            false,
+	} {
+		if err := CheckVarName(name); (err == nil) != valid {
+			t.Errorf("CheckVarName(%q) = %v, expected valid: %v", name, err, valid)
+		}
+	}
+}
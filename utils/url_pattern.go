@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CompileURLPattern compiles a url pattern the way Playwright matches them, either as a regex or
+// as a glob where `*` matches anything but `/`, `**` matches anything and `{a,b}` matches a or b
+func CompileURLPattern(pattern string, regex bool) (*regexp.Regexp, error) {
+	if regex {
+		return regexp.Compile(pattern)
+	}
+	return regexp.Compile(globToRegex(pattern))
+}
+
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	inGroup := false
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '*':
+			starCount := 1
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				starCount++
+				i++
+			}
+			switch {
+			case starCount == 1:
+				b.WriteString("[^/]*")
+			case i+1 < len(glob) && glob[i+1] == '/':
+				// `**/` matches any number of directories, including none
+				i++
+				b.WriteString("(?:.*/)?")
+			default:
+				b.WriteString(".*")
+			}
+		case c == '{':
+			inGroup = true
+			b.WriteString("(?:")
+		case c == '}' && inGroup:
+			inGroup = false
+			b.WriteString(")")
+		case c == ',' && inGroup:
+			b.WriteString("|")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -2,20 +2,43 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 )
 
 type (
-	getter = func() string
+	getter = func() any
 	Vars   map[string]varValue
 )
 
+// ResultsVar is the variable exposing the public results of the current run
+const ResultsVar = "results"
+
+// HiddenVarPrefix marks variables used by the engine itself, they are not exposed to templates
+const HiddenVarPrefix = "__$"
+
+// CheckVarName rejects the names pipelines give to variables that would replace the hidden ones
+func CheckVarName(name string) error {
+	if strings.HasPrefix(name, HiddenVarPrefix) {
+		return fmt.Errorf("invalid variable name %q, the %q prefix is reserved", name, HiddenVarPrefix)
+	}
+	return nil
+}
+
+// TemplateRecorderVar holds the TemplateRecorder of the current step, if any
+const TemplateRecorderVar = HiddenVarPrefix + "template-recorder"
+
+// TemplateRecorder is notified of the templates evaluated with the variables holding it (e.g. to trace a run)
+type TemplateRecorder interface {
+	RecordTemplate(source, result string)
+}
+
 type varValue struct {
 	isGenerative bool
-	value        string
+	value        any
 	get          getter
 }
 
-func (v *varValue) getValue() string {
+func (v *varValue) getValue() any {
 	if v.isGenerative {
 		return v.get()
 	} else {
@@ -23,9 +46,13 @@ func (v *varValue) getValue() string {
 	}
 }
 
-func (v Vars) Snapshot() map[string]string {
-	snap := make(map[string]string)
+// Snapshot returns the current (typed) value of every variable
+func (v Vars) Snapshot() map[string]any {
+	snap := make(map[string]any)
 	for k, g := range v {
+		if strings.HasPrefix(k, HiddenVarPrefix) {
+			continue
+		}
 		snap[k] = g.getValue()
 	}
 	return snap
@@ -34,12 +61,16 @@ func (v Vars) Snapshot() map[string]string {
 func (v Vars) LiveSnapshot() map[string]any {
 	snap := make(map[string]any)
 	for k, g := range v {
+		if strings.HasPrefix(k, HiddenVarPrefix) {
+			continue
+		}
 		snap[k] = g.getValue
 	}
 	return snap
 }
 
-func (v Vars) SetOnce(key string, value string) {
+// SetOnce stores a fixed value of any type (strings, numbers, booleans, lists, maps, ...) under the key
+func (v Vars) SetOnce(key string, value any) {
 	v[key] = varValue{
 		isGenerative: false,
 		value:        value,
@@ -53,13 +84,15 @@ func (v Vars) SetGetter(key string, getter getter) {
 	}
 }
 
-func (v Vars) Get(key string) (string, bool) {
+func (v Vars) Get(key string) (any, bool) {
 	item, ok := v[key]
-
-	return item.getValue(), ok
+	if !ok {
+		return nil, false
+	}
+	return item.getValue(), true
 }
 
-func (v Vars) GetOr(key string, def string) string {
+func (v Vars) GetOr(key string, def any) any {
 	value, ok := v.Get(key)
 	if ok {
 		return value
@@ -67,10 +100,39 @@ func (v Vars) GetOr(key string, def string) string {
 	return def
 }
 
-func (v Vars) GetOrFail(key string) (string, error) {
+func (v Vars) GetOrFail(key string) (any, error) {
 	value, ok := v.Get(key)
 	if ok {
 		return value, nil
 	}
-	return "", fmt.Errorf("use of undefined variable: %s", key)
+	return nil, fmt.Errorf("use of undefined variable: %s", key)
+}
+
+// Preserve remembers the current state of the keys and returns a function restoring it,
+// used to scope variables (loop keys, caught errors, ...) to a block
+func (v Vars) Preserve(keys ...string) (restore func()) {
+	saved := make(map[string]varValue, len(keys))
+	for _, key := range keys {
+		if value, ok := v[key]; ok {
+			saved[key] = value
+		}
+	}
+	return func() {
+		for _, key := range keys {
+			if value, ok := saved[key]; ok {
+				v[key] = value
+			} else {
+				delete(v, key)
+			}
+		}
+	}
+}
+
+// Clone returns a shallow copy of the variables, used to give concurrent executions their own scope
+func (v Vars) Clone() Vars {
+	clone := make(Vars, len(v))
+	for k, value := range v {
+		clone[k] = value
+	}
+	return clone
 }